@@ -47,14 +47,36 @@ var clusterConfig *ClusterConfig
 
 // 系统属性
 type Config struct {
-	DataStorePath      string                     `yaml:"data_store_dir"`        // 数据存储地址
-	HttpServerBind     string                     `yaml:"http_server_bind"`      // 监听端口绑定的IP
-	HttpServerPort     int                        `yaml:"http_server_port"`      // HTTP监听端口
-	SignSecretKey      string                     `yaml:"sign_secret_key"`       // 签名秘钥
-	ClusterNodeName    string                     `yaml:"cluster_node_name"`     // 集群节点名称
-	ClusterNodeTcpPort int                        `yaml:"cluster_node_tcp_port"` // 集群节点TCP监听端口
-	LoggerConfig       *logs.LoggerConfig         `yaml:"logger"`
-	DataSourceConfig   []*models.DataSourceConfig `yaml:"datasource"`
+	DataStorePath           string                     `yaml:"data_store_dir"`            // 数据存储地址
+	HttpServerBind          string                     `yaml:"http_server_bind"`          // 监听端口绑定的IP
+	HttpServerPort          int                        `yaml:"http_server_port"`          // HTTP监听端口
+	SignSecretKey           string                     `yaml:"sign_secret_key"`           // 签名秘钥
+	ClusterNodeName         string                     `yaml:"cluster_node_name"`         // 集群节点名称
+	ClusterNodeTcpPort      int                        `yaml:"cluster_node_tcp_port"`     // 集群节点TCP监听端口
+	ScheduleWorkers         int                        `yaml:"schedule_workers"`          // 分发调度任务的工作协程数量
+	BreakerFailureThreshold int                        `yaml:"breaker_failure_threshold"` // 执行节点连续失败多少次后熔断
+	BreakerCooldown         int                        `yaml:"breaker_cooldown"`          // 执行节点熔断冷却时间（秒）
+	HealthCheckInterval     int                        `yaml:"health_check_interval"`     // 执行节点探活间隔（秒）
+	ThrottleConfig          *ThrottleConfig            `yaml:"throttle"`                  // 限流配置
+	LoggerConfig            *logs.LoggerConfig         `yaml:"logger"`
+	DataSourceConfig        []*models.DataSourceConfig `yaml:"datasource"`
+}
+
+// 限流配置
+type ThrottleConfig struct {
+	Wait                int                       `yaml:"wait"`                 // 超过限制时最长排队等待时间（秒）
+	DispatchConcurrency int                       `yaml:"dispatch_concurrency"` // 全局同时发送的请求数，0为不限制
+	DispatchRate        int                       `yaml:"dispatch_rate"`        // 全局每秒发送的请求数，0为不限制
+	ExecutorConcurrency int                       `yaml:"executor_concurrency"` // 每个执行节点同时处理的请求数，0为不限制
+	ExecutorRate        int                       `yaml:"executor_rate"`        // 每个执行节点每秒处理的请求数，0为不限制
+	Executors           []*ExecutorThrottleConfig `yaml:"executors"`            // 按执行节点地址单独设置的限制
+}
+
+// 执行节点限流配置
+type ExecutorThrottleConfig struct {
+	Address     string `yaml:"address"`     // 执行节点地址
+	Concurrency int    `yaml:"concurrency"` // 同时处理的请求数，0为不限制
+	Rate        int    `yaml:"rate"`        // 每秒处理的请求数，0为不限制
 }
 
 type ClusterItemConfig struct {
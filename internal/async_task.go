@@ -0,0 +1,252 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/pkg/errors"
+)
+
+// 异步执行回调URI
+const asyncCallbackUriFormat = "/cluster/executions/%v/callback"
+
+// 异步执行
+type asyncExecution struct {
+	ctx      *scheduleContext          // 调度上下文
+	expected int                       // 需要等待的回调数量，0表示仍在发送请求
+	required int                       // 判定执行成功所需的成功回调数量
+	results  map[string]*AsyncCallback // 已收到的回调，按执行节点地址和分片参数去重
+	timer    *time.Timer               // 完成期限定时器
+}
+
+// 异步执行回调参数
+type AsyncCallback struct {
+	Succeed  bool   `json:"succeed"`  // 是否执行成功
+	Result   string `json:"result"`   // 执行结果
+	Address  string `json:"address"`  // 执行节点地址
+	Sharding string `json:"sharding"` // 分片参数，同一个执行节点执行多个分片时用于区分回调
+}
+
+var asyncExecutions = make(map[uint64]*asyncExecution)
+var asyncExecutionsLock sync.Mutex
+
+func newAsyncExecution(ctx *scheduleContext) *asyncExecution {
+	return &asyncExecution{
+		ctx:     ctx,
+		results: make(map[string]*AsyncCallback),
+	}
+}
+
+// 发送请求前登记异步执行，执行节点在请求返回前回调时不会丢失回调
+func prepareAsyncExecution(ctx *scheduleContext) {
+	asyncExecutionsLock.Lock()
+	defer asyncExecutionsLock.Unlock()
+
+	if _, exist := asyncExecutions[ctx.traceId]; !exist {
+		asyncExecutions[ctx.traceId] = newAsyncExecution(ctx)
+	}
+}
+
+// 等待执行节点回调或者完成期限到期
+// 收到expected个回调后结束，其中成功的回调不少于required个时判定为执行成功
+func awaitAsyncExecution(ctx *scheduleContext, expected int, required int, deadline time.Time) {
+	asyncExecutionsLock.Lock()
+	if !ctx.running {
+		ctx.await()
+	}
+	traceId := ctx.traceId
+	execution, exist := asyncExecutions[traceId]
+	if !exist {
+		execution = newAsyncExecution(ctx)
+		asyncExecutions[traceId] = execution
+	}
+	execution.expected = expected
+	execution.required = required
+	finished := len(execution.results) >= expected
+	if finished {
+		delete(asyncExecutions, traceId)
+	} else {
+		execution.timer = time.AfterFunc(time.Until(deadline), func() {
+			expireAsyncExecution(traceId)
+		})
+	}
+	asyncExecutionsLock.Unlock()
+
+	if finished {
+		// 发送请求期间已收到全部回调
+		execution.finish()
+		return
+	}
+	logs.Infof("Job(%s)异步执行(%v)等待回调，完成期限：%s", ctx.job.Name, traceId, deadline.Format(time.RFC3339))
+}
+
+// 按收到的回调判定执行结果
+func (this *asyncExecution) finish() {
+	succeeds := 0
+	failures := make([]string, 0)
+	for _, callback := range this.results {
+		if callback.Succeed {
+			succeeds++
+		} else {
+			failures = append(failures, callback.Result)
+		}
+	}
+	if succeeds >= this.required {
+		this.ctx.succeed()
+	} else {
+		this.ctx.failed("执行失败：" + strings.Join(failures, "；"))
+	}
+}
+
+// 完成期限到期
+func expireAsyncExecution(traceId uint64) {
+	execution := removeAsyncExecution(traceId)
+	if nil == execution {
+		return
+	}
+	logs.Warnf("Job(%s)异步执行(%v)超过完成期限", execution.ctx.job.Name, traceId)
+	execution.ctx.mutexDetail(fmt.Sprintf("已收到回调%d个，共需%d个", len(execution.results), execution.expected))
+	execution.ctx.failed("异步执行超时，未收到执行节点回调")
+}
+
+// 处理执行节点的异步执行回调，同一个执行节点(和分片参数)的重复回调被忽略
+func CompleteAsyncExecution(traceId uint64, callback *AsyncCallback) error {
+	asyncExecutionsLock.Lock()
+	execution, exist := asyncExecutions[traceId]
+	if !exist {
+		asyncExecutionsLock.Unlock()
+		return errors.Errorf("执行(%v)不存在或者已经结束", traceId)
+	}
+
+	key := callback.Address + "|" + callback.Sharding
+	if _, duplicated := execution.results[key]; duplicated {
+		asyncExecutionsLock.Unlock()
+		execution.ctx.mutexDetail(fmt.Sprintf("忽略重复的执行节点回调：%s", callback.Address))
+		return nil
+	}
+	execution.results[key] = callback
+	execution.ctx.mutexDetail(fmt.Sprintf("收到执行节点回调：%s，执行%s，%s", callback.Address, succeedText(callback.Succeed), callback.Result))
+	execution.ctx.completeShard(callback.Address, callback.Sharding, callback.Succeed)
+	finished := execution.expected > 0 && len(execution.results) >= execution.expected
+	if finished {
+		execution.timer.Stop()
+		delete(asyncExecutions, traceId)
+	}
+	asyncExecutionsLock.Unlock()
+
+	if finished {
+		execution.finish()
+	}
+	return nil
+}
+
+func succeedText(succeed bool) string {
+	if succeed {
+		return "成功"
+	}
+	return "失败"
+}
+
+// 恢复执行中的异步执行，主节点切换或者重启后调用
+func recoverAsyncExecutions() {
+	traces, err := models.SelectRunningTraces()
+	if err != nil {
+		logs.Errorf("查询执行中的调度跟踪信息失败：%s", err.Error())
+		return
+	}
+
+	for _, trace := range traces {
+		asyncExecutionsLock.Lock()
+		_, exist := asyncExecutions[trace.Id]
+		asyncExecutionsLock.Unlock()
+		if exist {
+			continue
+		}
+
+		job, err := models.GetJob(trace.JobId)
+		if err != nil {
+			logs.Warnf("恢复异步执行(%v)失败,查找Job信息错误:%s", trace.Id, err.Error())
+			continue
+		}
+		ctx := newScheduleContext(job, trace.ScheduleType, trace.StartTime)
+		ctx.traceId = trace.Id
+		ctx.details = strings.Split(trace.ExecuteDetail, detailLineSeparator)
+		ctx.responseBody = trace.ResponseBody
+		ctx.running = true
+		activeExecutionsLock.Lock()
+		activeExecutions[ctx.traceId] = ctx
+		activeExecutionsLock.Unlock()
+		ctx.detail("调度节点切换，恢复等待回调")
+		deadline := time.Unix(trace.StartTime, 0).Add(time.Duration(job.GetAsyncTimeout()) * time.Second)
+		// 无法得知切换前已收到的回调数量，按一个回调等待
+		awaitAsyncExecution(ctx, 1, 1, deadline)
+		logs.Infof("恢复异步执行:%s", stringutil.UintToStr(trace.Id))
+	}
+}
+
+// 清除异步执行，失去主节点身份时调用
+func clearAsyncExecutions() {
+	asyncExecutionsLock.Lock()
+	defer asyncExecutionsLock.Unlock()
+
+	for traceId, execution := range asyncExecutions {
+		if nil != execution.timer {
+			execution.timer.Stop()
+		}
+		delete(asyncExecutions, traceId)
+		releaseExecution(execution.ctx)
+	}
+}
+
+// 移除异步执行，返回被移除的异步执行，不存在时返回nil
+func removeAsyncExecution(traceId uint64) *asyncExecution {
+	asyncExecutionsLock.Lock()
+	defer asyncExecutionsLock.Unlock()
+
+	execution, exist := asyncExecutions[traceId]
+	if !exist {
+		return nil
+	}
+	if nil != execution.timer {
+		execution.timer.Stop()
+	}
+	delete(asyncExecutions, traceId)
+	return execution
+}
+
+// 移除正在等待回调的异步执行，仍在发送请求的不移除，由发送请求的流程结束
+func removeAwaitingAsyncExecution(traceId uint64) *asyncExecution {
+	asyncExecutionsLock.Lock()
+	defer asyncExecutionsLock.Unlock()
+
+	execution, exist := asyncExecutions[traceId]
+	if !exist || 0 == execution.expected {
+		return nil
+	}
+	execution.timer.Stop()
+	delete(asyncExecutions, traceId)
+	return execution
+}
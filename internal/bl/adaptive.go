@@ -0,0 +1,207 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package bl
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"gojob/util/dateutil"
+)
+
+const (
+	// 响应时间EWMA的平滑系数，越大越偏向最近的样本
+	ewmaAlpha = 0.3
+	// 请求失败时按不低于此值的耗时计入EWMA，避免快速失败的执行节点被优先选中
+	FailedLatencyPenalty = 5 * time.Second
+)
+
+// 执行节点的运行统计，由本调度节点的执行结果累积
+type executorStat struct {
+	lock     sync.Mutex
+	active   int64   // 执行中的请求数
+	ewma     float64 // 响应时间的指数加权移动平均（毫秒），0表示尚无样本
+	succeed  int64
+	failed   int64
+	lastTime time.Time
+}
+
+// 执行节点统计快照
+type ExecutorStat struct {
+	Address     string  `json:"address"`     // 执行节点地址
+	Active      int64   `json:"active"`      // 执行中的请求数
+	EwmaMillis  float64 `json:"ewmaMillis"`  // 响应时间EWMA（毫秒）
+	Succeed     int64   `json:"succeed"`     // 成功次数
+	Failed      int64   `json:"failed"`      // 失败次数
+	LastRequest string  `json:"lastRequest"` // 最近一次请求完成时间
+}
+
+var stats sync.Map
+
+func getStat(address string) *executorStat {
+	if stat, exist := stats.Load(address); exist {
+		return stat.(*executorStat)
+	}
+	stat, _ := stats.LoadOrStore(address, &executorStat{})
+	return stat.(*executorStat)
+}
+
+// 开始向执行节点发起请求
+func BeginRequest(address string) {
+	stat := getStat(address)
+	stat.lock.Lock()
+	stat.active++
+	stat.lock.Unlock()
+}
+
+// 请求执行节点结束，记录耗时与结果
+func EndRequest(address string, elapsed time.Duration, succeed bool) {
+	if !succeed && elapsed < FailedLatencyPenalty {
+		elapsed = FailedLatencyPenalty
+	}
+	millis := float64(elapsed) / float64(time.Millisecond)
+
+	stat := getStat(address)
+	stat.lock.Lock()
+	defer stat.lock.Unlock()
+
+	if stat.active > 0 {
+		stat.active--
+	}
+	if 0 == stat.ewma {
+		stat.ewma = millis
+	} else {
+		stat.ewma = ewmaAlpha*millis + (1-ewmaAlpha)*stat.ewma
+	}
+	if succeed {
+		stat.succeed++
+	} else {
+		stat.failed++
+	}
+	stat.lastTime = time.Now()
+}
+
+// 获取所有执行节点的统计快照，按地址排序
+func ExecutorStats() []*ExecutorStat {
+	result := make([]*ExecutorStat, 0)
+	stats.Range(func(key, value interface{}) bool {
+		stat := value.(*executorStat)
+		stat.lock.Lock()
+		snapshot := &ExecutorStat{
+			Address:    key.(string),
+			Active:     stat.active,
+			EwmaMillis: stat.ewma,
+			Succeed:    stat.succeed,
+			Failed:     stat.failed,
+		}
+		if !stat.lastTime.IsZero() {
+			snapshot.LastRequest = dateutil.DefaultLayout(stat.lastTime)
+		}
+		stat.lock.Unlock()
+		result = append(result, snapshot)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
+
+// 读取执行节点当前的执行中请求数和响应时间EWMA
+func loadStat(address string) (int64, float64) {
+	stat, exist := stats.Load(address)
+	if !exist {
+		return 0, 0
+	}
+	s := stat.(*executorStat)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.active, s.ewma
+}
+
+// 在得分最低的执行节点中按权重随机选择一个
+func selectLowest(items []LoadItem, score func(item LoadItem) float64) int {
+	lowest := make([]LoadItem, 0, len(items))
+	var min float64
+	for _, item := range items {
+		s := score(item)
+		if len(lowest) == 0 || s < min {
+			min = s
+			lowest = append(lowest[:0], item)
+		} else if s == min {
+			lowest = append(lowest, item)
+		}
+	}
+	if len(lowest) == 0 {
+		return -1
+	}
+	total := 0
+	for _, item := range lowest {
+		total += itemWeight(item)
+	}
+	offset := rand.Intn(total)
+	for _, item := range lowest {
+		offset -= itemWeight(item)
+		if offset < 0 {
+			return item.Index
+		}
+	}
+	return lowest[0].Index
+}
+
+func itemWeight(item LoadItem) int {
+	if item.Weight <= 0 {
+		return 1
+	}
+	return item.Weight
+}
+
+// -------------------- 最少活跃负载均衡
+// 选择本调度节点上执行中请求最少的执行节点，数量相同时按权重随机
+type LeastActiveLoadBalance struct {
+}
+
+func NewLeastActiveLoadBalance() LoadBalance {
+	return &LeastActiveLoadBalance{}
+}
+
+func (this *LeastActiveLoadBalance) DoSelect(items []LoadItem) int {
+	return selectLowest(items, func(item LoadItem) float64 {
+		active, _ := loadStat(item.Key)
+		return float64(active)
+	})
+}
+
+// -------------------- 响应时间加权负载均衡
+// 按 响应时间EWMA × (执行中请求数+1) ÷ 权重 打分，选择得分最低的执行节点；
+// 尚无样本的执行节点得分为0，会被优先选中以获取样本
+type ResponseTimeLoadBalance struct {
+}
+
+func NewResponseTimeLoadBalance() LoadBalance {
+	return &ResponseTimeLoadBalance{}
+}
+
+func (this *ResponseTimeLoadBalance) DoSelect(items []LoadItem) int {
+	return selectLowest(items, func(item LoadItem) float64 {
+		active, ewma := loadStat(item.Key)
+		return ewma * float64(active+1) / float64(itemWeight(item))
+	})
+}
@@ -0,0 +1,73 @@
+package bl
+
+import (
+	"testing"
+	"time"
+)
+
+// 清除测试使用的执行节点统计，保证重复运行时互不影响
+func resetStats(items []LoadItem) {
+	for _, item := range items {
+		stats.Delete(item.Key)
+	}
+}
+
+func TestLeastActive(t *testing.T) {
+	items := []LoadItem{
+		{Index: 0, Weight: 1, Key: "least-active-a"},
+		{Index: 1, Weight: 1, Key: "least-active-b"},
+	}
+	resetStats(items)
+	defer resetStats(items)
+	BeginRequest("least-active-a")
+	defer EndRequest("least-active-a", time.Millisecond, true)
+
+	lb := NewLeastActiveLoadBalance()
+	for i := 0; i < 100; i++ {
+		if selected := lb.DoSelect(items); selected != 1 {
+			t.Fatalf("expected the idle executor, actual %d", selected)
+		}
+	}
+}
+
+func TestResponseTime(t *testing.T) {
+	items := []LoadItem{
+		{Index: 0, Weight: 1, Key: "response-time-slow"},
+		{Index: 1, Weight: 1, Key: "response-time-fast"},
+		{Index: 2, Weight: 1, Key: "response-time-failing"},
+	}
+	resetStats(items)
+	defer resetStats(items)
+	for i := 0; i < 10; i++ {
+		BeginRequest("response-time-slow")
+		EndRequest("response-time-slow", 800*time.Millisecond, true)
+		BeginRequest("response-time-fast")
+		EndRequest("response-time-fast", 50*time.Millisecond, true)
+		// 快速失败按惩罚耗时计入
+		BeginRequest("response-time-failing")
+		EndRequest("response-time-failing", time.Millisecond, false)
+	}
+
+	lb := NewResponseTimeLoadBalance()
+	if selected := lb.DoSelect(items); selected != 1 {
+		t.Fatalf("expected the fast executor, actual %d", selected)
+	}
+
+	// 快速节点积压请求后，慢节点的综合得分更优
+	for i := 0; i < 20; i++ {
+		BeginRequest("response-time-fast")
+	}
+	selected := lb.DoSelect(items)
+	for i := 0; i < 20; i++ {
+		EndRequest("response-time-fast", 50*time.Millisecond, true)
+	}
+	if selected != 0 {
+		t.Fatalf("expected the slow but idle executor, actual %d", selected)
+	}
+
+	for _, stat := range ExecutorStats() {
+		if "response-time-failing" == stat.Address && stat.Failed != 10 {
+			t.Errorf("expected 10 failures, actual %d", stat.Failed)
+		}
+	}
+}
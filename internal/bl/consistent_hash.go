@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package bl
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 每个执行节点默认的虚拟节点数量
+const DefaultVirtualNodes = 160
+
+// 哈希环上的虚拟节点
+type virtualNode struct {
+	hash uint32
+	key  string
+}
+
+// -------------------- 一致性哈希负载均衡
+// 同一个哈希键总是落在同一个执行节点上，增减执行节点时只有相邻区间的哈希键会被重新映射
+type ConsistentHashLoadBalance struct {
+	replicas  int
+	lock      sync.Mutex
+	signature string // 当前哈希环对应的执行节点集合，集合不变时复用哈希环
+	ring      []virtualNode
+}
+
+func NewConsistentHashLoadBalance(replicas int) *ConsistentHashLoadBalance {
+	if replicas <= 0 {
+		replicas = DefaultVirtualNodes
+	}
+	return &ConsistentHashLoadBalance{
+		replicas: replicas,
+	}
+}
+
+// 未指定哈希键时，固定选中哈希环上的同一个节点
+func (this *ConsistentHashLoadBalance) DoSelect(items []LoadItem) int {
+	return this.DoSelectByKey(items, "")
+}
+
+// 根据哈希键选择执行节点，LoadItem.Key为执行节点的唯一标识
+func (this *ConsistentHashLoadBalance) DoSelectByKey(items []LoadItem, key string) int {
+	if len(items) == 0 {
+		return -1
+	}
+	ring := this.getRing(items)
+	hash := hashKey(key)
+	i := sort.Search(len(ring), func(i int) bool {
+		return ring[i].hash >= hash
+	})
+	if i == len(ring) {
+		i = 0
+	}
+	for _, item := range items {
+		if item.Key == ring[i].key {
+			return item.Index
+		}
+	}
+	return -1
+}
+
+// 执行节点集合变化时重建哈希环
+func (this *ConsistentHashLoadBalance) getRing(items []LoadItem) []virtualNode {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	sort.Strings(keys)
+	signature := strings.Join(keys, ",")
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if signature == this.signature && nil != this.ring {
+		return this.ring
+	}
+	ring := make([]virtualNode, 0, len(keys)*this.replicas)
+	for _, key := range keys {
+		// 一个md5摘要可以切分出4个虚拟节点
+		for i := 0; i < (this.replicas+3)/4; i++ {
+			digest := md5.Sum([]byte(key + "#" + strconv.Itoa(i)))
+			for j := 0; j < 4; j++ {
+				ring = append(ring, virtualNode{
+					hash: binary.LittleEndian.Uint32(digest[j*4 : j*4+4]),
+					key:  key,
+				})
+			}
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash == ring[j].hash {
+			return ring[i].key < ring[j].key
+		}
+		return ring[i].hash < ring[j].hash
+	})
+	this.signature = signature
+	this.ring = ring
+	return ring
+}
+
+func hashKey(key string) uint32 {
+	digest := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(digest[0:4])
+}
@@ -0,0 +1,98 @@
+package bl
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func newHashItems(count int) []LoadItem {
+	items := make([]LoadItem, count)
+	for i := 0; i < count; i++ {
+		items[i] = LoadItem{Index: i, Weight: 1, Key: fmt.Sprintf("10.0.0.%d:8080", i+1)}
+	}
+	return items
+}
+
+func selectKeys(lb *ConsistentHashLoadBalance, items []LoadItem, keys int) map[string]string {
+	result := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := strconv.Itoa(i)
+		result[key] = items[lb.DoSelectByKey(items, key)].Key
+	}
+	return result
+}
+
+func TestConsistentHashStable(t *testing.T) {
+	lb := NewConsistentHashLoadBalance(DefaultVirtualNodes)
+	items := newHashItems(5)
+	reversed := make([]LoadItem, len(items))
+	for i, item := range items {
+		reversed[len(items)-1-i] = LoadItem{Index: len(items) - 1 - i, Weight: item.Weight, Key: item.Key}
+	}
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		expected := items[lb.DoSelectByKey(items, key)].Key
+		if actual := items[lb.DoSelectByKey(items, key)].Key; actual != expected {
+			t.Fatalf("key %s: expected %s, actual %s", key, expected, actual)
+		}
+		// 执行节点顺序变化不影响选择结果
+		if actual := reversed[lb.DoSelectByKey(reversed, key)].Key; actual != expected {
+			t.Fatalf("key %s after reorder: expected %s, actual %s", key, expected, actual)
+		}
+	}
+}
+
+func TestConsistentHashDistribution(t *testing.T) {
+	const nodes, keys = 10, 100000
+	lb := NewConsistentHashLoadBalance(DefaultVirtualNodes)
+	items := newHashItems(nodes)
+	counts := make(map[string]int)
+	for _, node := range selectKeys(lb, items, keys) {
+		counts[node]++
+	}
+	mean := keys / nodes
+	for _, item := range items {
+		count := counts[item.Key]
+		if count < mean*7/10 || count > mean*13/10 {
+			t.Errorf("%s got %d keys, expected about %d", item.Key, count, mean)
+		}
+	}
+}
+
+func TestConsistentHashRemapping(t *testing.T) {
+	const nodes, keys = 10, 100000
+	lb := NewConsistentHashLoadBalance(DefaultVirtualNodes)
+	items := newHashItems(nodes)
+	before := selectKeys(lb, items, keys)
+
+	// 增加一个执行节点：只有迁移到新节点的哈希键发生变化，比例约为1/(n+1)
+	added := newHashItems(nodes + 1)
+	newKey := added[nodes].Key
+	moved := 0
+	for key, node := range selectKeys(lb, added, keys) {
+		if node != before[key] {
+			moved++
+			if node != newKey {
+				t.Fatalf("key %s moved from %s to %s instead of the new node", key, before[key], node)
+			}
+		}
+	}
+	if ratio := float64(moved) / keys; ratio > 1.5/float64(nodes+1) {
+		t.Errorf("adding a node remapped %.2f%% keys", ratio*100)
+	}
+
+	// 移除一个执行节点：只有原先落在该节点上的哈希键发生变化
+	removed := items[3].Key
+	remains := make([]LoadItem, 0, nodes-1)
+	for _, item := range items {
+		if item.Key != removed {
+			remains = append(remains, LoadItem{Index: len(remains), Weight: item.Weight, Key: item.Key})
+		}
+	}
+	for key, node := range selectKeys(lb, remains, keys) {
+		if before[key] != removed && node != before[key] {
+			t.Fatalf("key %s moved from %s to %s although its node is still present", key, before[key], node)
+		}
+	}
+}
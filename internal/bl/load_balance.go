@@ -29,6 +29,7 @@ var lbs sync.Map
 type LoadItem struct {
 	Index  int
 	Weight int
+	Key    string // 执行节点的唯一标识，一致性哈希使用
 }
 
 type LoadBalance interface {
@@ -63,7 +64,12 @@ func (this *RoundLoadBalance) DoSelect(items []LoadItem) int {
 }
 
 // -------------------- 加权随机负载均衡
+// 使用别名法(Vose's alias method)，每次选择为O(1)；别名表按执行节点集合缓存，集合或权重变化时重建
 type WeightRandomLoadBalance struct {
+	lock  sync.Mutex
+	items []LoadItem // 当前别名表对应的执行节点集合
+	prob  []float64
+	alias []int
 }
 
 func NewWeightRandomLoadBalance() LoadBalance {
@@ -71,18 +77,80 @@ func NewWeightRandomLoadBalance() LoadBalance {
 }
 
 func (this *WeightRandomLoadBalance) DoSelect(items []LoadItem) int {
-	ns := make([]int, 0)
+	if len(items) == 0 {
+		return -1
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if !sameItems(this.items, items) {
+		this.items = append(this.items[:0], items...)
+		this.prob, this.alias = buildAlias(items)
+	}
+	i := rand.Intn(len(items))
+	if rand.Float64() < this.prob[i] {
+		return items[i].Index
+	}
+	return items[this.alias[i]].Index
+}
+
+// 构建别名表，权重全部为0时等概率选择
+func buildAlias(items []LoadItem) ([]float64, []int) {
+	n := len(items)
+	total := 0
 	for _, item := range items {
-		for i := 0; i < item.Weight; i++ {
-			ns = append(ns, item.Index)
+		total += positiveWeight(item)
+	}
+	prob := make([]float64, n)
+	alias := make([]int, n)
+	scaled := make([]float64, n)
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for i, item := range items {
+		if 0 == total {
+			scaled[i] = 1
+		} else {
+			scaled[i] = float64(positiveWeight(item)) * float64(n) / float64(total)
 		}
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		prob[s] = scaled[s]
+		alias[s] = l
+		scaled[l] = scaled[l] + scaled[s] - 1
+		if scaled[l] < 1 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+	// 剩余项的概率受浮点误差影响可能略小于1，统一置为1
+	for _, i := range large {
+		prob[i] = 1
 	}
-	return ns[rand.Intn(len(ns))]
+	for _, i := range small {
+		prob[i] = 1
+	}
+	return prob, alias
 }
 
 // -------------------- 加权轮询负载均衡
+// 使用平滑加权轮询(与nginx相同)，权重为5、1、1时选择序列为 A A B A C A A，不会连续集中在同一个执行节点；
+// 状态按执行节点集合保存，集合或权重变化时重置
 type WeightRoundLoadBalance struct {
-	round atomic.Int64
+	lock     sync.Mutex
+	items    []LoadItem // 当前状态对应的执行节点集合
+	currents []int      // 每个执行节点的当前权重
 }
 
 func NewWeightRoundLoadBalance() LoadBalance {
@@ -90,13 +158,53 @@ func NewWeightRoundLoadBalance() LoadBalance {
 }
 
 func (this *WeightRoundLoadBalance) DoSelect(items []LoadItem) int {
-	ns := make([]int, 0)
-	for _, item := range items {
-		for i := 0; i < item.Weight; i++ {
-			ns = append(ns, item.Index)
+	if len(items) == 0 {
+		return -1
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if !sameItems(this.items, items) {
+		this.items = append(this.items[:0], items...)
+		this.currents = make([]int, len(items))
+	}
+	total := 0
+	best := -1
+	for i, item := range items {
+		weight := positiveWeight(item)
+		this.currents[i] += weight
+		total += weight
+		if -1 == best || this.currents[i] > this.currents[best] {
+			best = i
 		}
 	}
-	this.round.Add(1)
-	index := this.round.Load() % int64(len(ns))
-	return ns[index]
+	if 0 == total { // 权重全部为0时退化为轮询
+		total = len(items)
+		for i := range this.currents {
+			this.currents[i]++
+		}
+	}
+	this.currents[best] -= total
+	return items[best].Index
+}
+
+func positiveWeight(item LoadItem) int {
+	if item.Weight < 0 {
+		return 0
+	}
+	return item.Weight
+}
+
+// 执行节点集合(顺序、标识及权重)是否相同
+func sameItems(a []LoadItem, b []LoadItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,123 @@
+package bl
+
+import (
+	"math"
+	"testing"
+)
+
+func newWeightItems(weights ...int) []LoadItem {
+	items := make([]LoadItem, len(weights))
+	for i, weight := range weights {
+		items[i] = LoadItem{Index: i, Weight: weight, Key: string(rune('A' + i))}
+	}
+	return items
+}
+
+func TestWeightRoundSmooth(t *testing.T) {
+	lb := NewWeightRoundLoadBalance()
+	items := newWeightItems(5, 1, 1)
+	expected := "AABACAA"
+	for round := 0; round < 3; round++ {
+		sequence := ""
+		for i := 0; i < len(expected); i++ {
+			sequence += items[lb.DoSelect(items)].Key
+		}
+		if sequence != expected {
+			t.Fatalf("expected %s, actual %s", expected, sequence)
+		}
+	}
+}
+
+func TestWeightRoundFairness(t *testing.T) {
+	lb := NewWeightRoundLoadBalance()
+	items := newWeightItems(1000, 300, 1, 0)
+	counts := make([]int, len(items))
+	total := 1301
+	last, run, maxRun := -1, 0, 0
+	for i := 0; i < total*10; i++ {
+		selected := lb.DoSelect(items)
+		counts[selected]++
+		if selected == last {
+			run++
+		} else {
+			last, run = selected, 1
+		}
+		if selected != 0 && run > maxRun {
+			maxRun = run
+		}
+	}
+	// 每个完整周期内的选择次数与权重严格成比例
+	for i, item := range items {
+		if counts[i] != item.Weight*10 {
+			t.Errorf("%s expected %d, actual %d", item.Key, item.Weight*10, counts[i])
+		}
+	}
+	// 低权重节点被均匀穿插，不会连续选中
+	if maxRun > 1 {
+		t.Errorf("low weight executor selected %d times in a row", maxRun)
+	}
+
+	// 执行节点集合变化后按新的权重分配
+	items = newWeightItems(1, 1)
+	counts = make([]int, len(items))
+	for i := 0; i < 100; i++ {
+		counts[lb.DoSelect(items)]++
+	}
+	if counts[0] != 50 || counts[1] != 50 {
+		t.Errorf("expected 50/50 after executors changed, actual %v", counts)
+	}
+}
+
+func TestWeightRandomFairness(t *testing.T) {
+	lb := NewWeightRandomLoadBalance()
+	items := newWeightItems(1000, 300, 100, 0, 600)
+	const n = 200000
+	counts := make([]int, len(items))
+	for i := 0; i < n; i++ {
+		counts[lb.DoSelect(items)]++
+	}
+	for i, item := range items {
+		expected := float64(n) * float64(item.Weight) / 2000
+		if math.Abs(float64(counts[i])-expected) > n*0.01 {
+			t.Errorf("%s expected about %.0f, actual %d", item.Key, expected, counts[i])
+		}
+	}
+}
+
+func TestWeightZero(t *testing.T) {
+	items := newWeightItems(0, 0, 0)
+	random := NewWeightRandomLoadBalance()
+	round := NewWeightRoundLoadBalance()
+	counts := make([]int, len(items))
+	for i := 0; i < 30; i++ {
+		counts[round.DoSelect(items)]++
+		if selected := random.DoSelect(items); selected < 0 || selected >= len(items) {
+			t.Fatalf("unexpected selection %d", selected)
+		}
+	}
+	for i, count := range counts {
+		if count != 10 {
+			t.Errorf("%s expected 10, actual %d", items[i].Key, count)
+		}
+	}
+}
+
+func BenchmarkWeightRound(b *testing.B) {
+	lb := NewWeightRoundLoadBalance()
+	items := newWeightItems(1000, 800, 500, 1000, 200, 1000, 50, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lb.DoSelect(items)
+	}
+}
+
+func BenchmarkWeightRandom(b *testing.B) {
+	lb := NewWeightRandomLoadBalance()
+	items := newWeightItems(1000, 800, 500, 1000, 200, 1000, 50, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lb.DoSelect(items)
+	}
+}
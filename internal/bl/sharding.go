@@ -44,3 +44,84 @@ func Sharding(shardingTotal int, instanceCount int) [][]int {
 	}
 	return result
 }
+
+// 按权重分片，每个实例分得的分片数量与权重成比例(最大余数法)，分片按实例顺序连续分配；
+// 权重全部为0时与Sharding相同
+// 如果有3个实例 权重为[3 1 1] 分为10片，每个实列得到的分片结果为：[[0 1 2 3 4 5] [6 7] [8 9]]
+func WeightSharding(shardingTotal int, weights []int) [][]int {
+	total := 0
+	for _, weight := range weights {
+		if weight > 0 {
+			total += weight
+		}
+	}
+	if 0 == total {
+		return Sharding(shardingTotal, len(weights))
+	}
+
+	counts := make([]int, len(weights))
+	remainders := make([]int, len(weights))
+	assigned := 0
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		counts[i] = shardingTotal * weight / total
+		remainders[i] = shardingTotal * weight % total
+		assigned += counts[i]
+	}
+	// 剩余的分片依次分给余数最大的实例，余数相同时靠前的优先
+	for ; assigned < shardingTotal; assigned++ {
+		max := -1
+		for i := range weights {
+			if weights[i] > 0 && (-1 == max || remainders[i] > remainders[max]) {
+				max = i
+			}
+		}
+		counts[max]++
+		remainders[max] = -1
+	}
+
+	result := make([][]int, len(weights))
+	index := 0
+	for i, count := range counts {
+		items := make([]int, 0, count)
+		for j := 0; j < count; j++ {
+			items = append(items, index)
+			index++
+		}
+		result[i] = items
+	}
+	return result
+}
+
+// 粘性分片，按rendezvous hashing把每个分片参数分配给得分最高的实例，返回每个实例分得的分片参数下标，keys为实例标识。
+// 分配结果只取决于分片参数和实例集合，不保存状态，调度节点重启或主节点切换后保持不变；
+// 分片只会在所在实例离开时迁移到得分次高的实例，或者迁移到得分更高的新加入实例，不会在留存的实例之间迁移
+func StickySharding(params []string, keys []string) [][]int {
+	result := make([][]int, len(keys))
+	for i := range keys {
+		result[i] = make([]int, 0)
+	}
+	if len(keys) == 0 {
+		return result
+	}
+
+	for i, param := range params {
+		best := 0
+		bestScore := rendezvousScore(param, keys[0])
+		for position := 1; position < len(keys); position++ {
+			score := rendezvousScore(param, keys[position])
+			if score > bestScore || (score == bestScore && keys[position] < keys[best]) {
+				best = position
+				bestScore = score
+			}
+		}
+		result[best] = append(result[best], i)
+	}
+	return result
+}
+
+func rendezvousScore(param string, key string) uint32 {
+	return hashKey(param + "#" + key)
+}
@@ -0,0 +1,90 @@
+package bl
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestWeightSharding(t *testing.T) {
+	cases := []struct {
+		total    int
+		weights  []int
+		expected [][]int
+	}{
+		{10, []int{3, 1, 1}, [][]int{{0, 1, 2, 3, 4, 5}, {6, 7}, {8, 9}}},
+		{4, []int{1, 0, 1}, [][]int{{0, 1}, {}, {2, 3}}},
+		{9, []int{0, 0, 0}, [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}}},
+		{2, []int{1, 1, 1}, [][]int{{0}, {1}, {}}},
+	}
+	for _, c := range cases {
+		if actual := WeightSharding(c.total, c.weights); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("WeightSharding(%d, %v): expected %v, actual %v", c.total, c.weights, c.expected, actual)
+		}
+	}
+
+	counts := WeightSharding(1000, []int{500, 300, 200})
+	for i, expected := range []int{500, 300, 200} {
+		if len(counts[i]) != expected {
+			t.Errorf("executor %d expected %d shards, actual %d", i, expected, len(counts[i]))
+		}
+	}
+}
+
+func stickyAssignments(sharding [][]int, params []string, keys []string) map[string]string {
+	result := make(map[string]string)
+	for i, items := range sharding {
+		for _, item := range items {
+			result[params[item]] = keys[i]
+		}
+	}
+	return result
+}
+
+func TestStickySharding(t *testing.T) {
+	params := make([]string, 30)
+	for i := range params {
+		params[i] = "tenant-" + strconv.Itoa(i)
+	}
+	keys := []string{"a:8080", "b:8080", "c:8080"}
+	before := stickyAssignments(StickySharding(params, keys), params, keys)
+	if len(before) != len(params) {
+		t.Fatalf("expected %d assigned shards, actual %d", len(params), len(before))
+	}
+	for i, items := range StickySharding(params, keys) {
+		if len(items) == 0 {
+			t.Errorf("executor %s got no shards", keys[i])
+		}
+	}
+
+	// 分配结果只取决于分片参数和执行节点集合
+	reversed := []string{"c:8080", "b:8080", "a:8080"}
+	if actual := stickyAssignments(StickySharding(params, reversed), params, reversed); !reflect.DeepEqual(actual, before) {
+		t.Errorf("assignment changed with executor order: %v, %v", before, actual)
+	}
+
+	// 新增执行节点：迁移的分片全部由新的执行节点接手
+	joined := []string{"d:8080", "c:8080", "b:8080", "a:8080"}
+	after := stickyAssignments(StickySharding(params, joined), params, joined)
+	moved := 0
+	for param, key := range before {
+		if after[param] != key {
+			moved++
+			if "d:8080" != after[param] {
+				t.Errorf("%s moved from %s to %s although both executors are still present", param, key, after[param])
+			}
+		}
+	}
+	if moved == 0 {
+		t.Errorf("executor joined: no shards moved to the new executor")
+	}
+
+	// 执行节点离开：其余执行节点的分片保持不变
+	left := []string{"a:8080", "c:8080", "d:8080"}
+	remains := stickyAssignments(StickySharding(params, left), params, left)
+	for param, key := range after {
+		if "b:8080" != key && remains[param] != key {
+			t.Errorf("%s moved from %s to %s although its executor is still present", param, key, remains[param])
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/logs"
+)
+
+const (
+	// 熔断器状态 -- 关闭，正常调用
+	breakerStateClosed = "closed"
+	// 熔断器状态 -- 打开，跳过该执行节点
+	breakerStateOpen = "open"
+	// 熔断器状态 -- 半开，冷却时间已过，允许一次试探调用
+	breakerStateHalfOpen = "half_open"
+	// 默认连续失败多少次后熔断
+	defaultBreakerFailureThreshold = 5
+	// 默认熔断冷却时间（秒）
+	defaultBreakerCooldown = 30
+)
+
+// 执行节点熔断器，按执行节点地址维护
+type circuitBreaker struct {
+	state    string
+	failures int       // 连续失败次数
+	openedAt time.Time // 进入打开状态的时间
+	trialAt  time.Time // 半开状态下放行试探调用的时间，零值表示尚未放行
+}
+
+// 执行节点熔断状态
+type ExecutorBreaker struct {
+	Address    string `json:"address"`    // 执行节点地址
+	State      string `json:"state"`      // 熔断器状态 closed open half_open
+	Failures   int    `json:"failures"`   // 连续失败次数
+	OpenedTime string `json:"openedTime"` // 熔断时间
+}
+
+var breakers = make(map[string]*circuitBreaker)
+var breakersLock sync.Mutex
+var breakerFailureThreshold = defaultBreakerFailureThreshold
+var breakerCooldown = defaultBreakerCooldown * time.Second
+
+// 初始化熔断参数，小于等于0时使用默认值
+func InitCircuitBreaker(failureThreshold int, cooldown int) {
+	if failureThreshold > 0 {
+		breakerFailureThreshold = failureThreshold
+	}
+	if cooldown > 0 {
+		breakerCooldown = time.Duration(cooldown) * time.Second
+	}
+}
+
+func getBreaker(address string) *circuitBreaker {
+	breaker, exist := breakers[address]
+	if !exist {
+		breaker = &circuitBreaker{state: breakerStateClosed}
+		breakers[address] = breaker
+	}
+	return breaker
+}
+
+// 执行节点是否可以被选中；打开状态超过冷却时间后转为半开，半开状态只放行一次试探调用，
+// 放行与判断在同一次加锁中完成，并发的调度不会同时放行多个试探调用；
+// 放行后未被选中或未上报结果的试探在一个冷却时间后失效，可以再次放行
+func executorAvailable(address string) bool {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+
+	breaker, exist := breakers[address]
+	if !exist {
+		return true
+	}
+	switch breaker.state {
+	case breakerStateOpen:
+		if time.Since(breaker.openedAt) < breakerCooldown {
+			return false
+		}
+		breaker.state = breakerStateHalfOpen
+		breaker.trialAt = time.Now()
+		logs.Infof("执行节点%s熔断冷却结束，进入半开状态", address)
+		return true
+	case breakerStateHalfOpen:
+		if !breaker.trialAt.IsZero() && time.Since(breaker.trialAt) < breakerCooldown {
+			return false
+		}
+		breaker.trialAt = time.Now()
+		return true
+	}
+	return true
+}
+
+// 记录执行节点的调用或探活结果，驱动熔断器状态转换；
+// 熔断(关闭->打开)和恢复(->关闭)时发送告警，半开试探失败重新打开时只记录日志，避免每个冷却周期重复告警
+func reportExecutorResult(address string, succeed bool, reason string) {
+	breakersLock.Lock()
+	breaker := getBreaker(address)
+	previous := breaker.state
+	if succeed {
+		breaker.state = breakerStateClosed
+		breaker.failures = 0
+		breaker.trialAt = time.Time{}
+	} else {
+		breaker.failures++
+		if breakerStateHalfOpen == breaker.state ||
+			(breakerStateClosed == breaker.state && breaker.failures >= breakerFailureThreshold) {
+			breaker.state = breakerStateOpen
+			breaker.openedAt = time.Now()
+			breaker.trialAt = time.Time{}
+		}
+	}
+	current := breaker.state
+	failures := breaker.failures
+	breakersLock.Unlock()
+
+	if previous == current {
+		return
+	}
+	switch {
+	case breakerStateOpen == current && breakerStateClosed == previous:
+		logs.Warnf("执行节点%s连续失败%d次，熔断：%s", address, failures, reason)
+		alarmBreaker(address, "Go-Job告警,执行节点熔断",
+			fmt.Sprintf("告警时间：%s  <br>执行节点：%s ，连续失败%d次，已熔断，%s后尝试恢复 <br>最近一次失败：%s",
+				dateutil.NowFormatted(), address, failures, breakerCooldown, reason))
+	case breakerStateOpen == current:
+		logs.Warnf("执行节点%s半开试探失败，重新熔断：%s", address, reason)
+	case breakerStateClosed == current:
+		logs.Infof("执行节点%s恢复正常", address)
+		alarmBreaker(address, "Go-Job告警恢复,执行节点恢复",
+			fmt.Sprintf("恢复时间：%s  <br>执行节点：%s ，已恢复正常调用", dateutil.NowFormatted(), address))
+	}
+}
+
+func alarmBreaker(address string, subject string, body string) {
+	if !IsStandaloneOrLeader() {
+		return
+	}
+	conf, err := models.GetAlarmConfig()
+	if nil != err || "" == conf.SysAlarmEmail {
+		return
+	}
+	models.SendAlarmEmail(&models.AlarmEmail{
+		Toers:   conf.SysAlarmEmail,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// 获取所有执行节点的熔断状态，按地址排序
+func GetExecutorBreakers() []*ExecutorBreaker {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+
+	result := make([]*ExecutorBreaker, 0, len(breakers))
+	for address, breaker := range breakers {
+		item := &ExecutorBreaker{
+			Address:  address,
+			State:    breaker.state,
+			Failures: breaker.failures,
+		}
+		if breakerStateClosed != breaker.state {
+			item.OpenedTime = dateutil.DefaultLayout(breaker.openedAt)
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
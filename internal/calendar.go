@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/logs"
+)
+
+// 等待顺延执行的作业，每个作业同时只保留一次顺延
+var deferredExecutions = make(map[uint64]*time.Timer)
+var deferredExecutionsLock sync.Mutex
+
+// 触发时间点被作业引用的日历排除时，按作业的日历策略跳过或顺延，返回true表示已处理
+// 顺延只保存在当前调度节点的内存中，主节点切换后未执行的顺延不会保留
+func (this *HttpTask) applyCalendars(job *models.Job, fireTime time.Time, scheduleType int) bool {
+	if len(job.CalendarIds) == 0 {
+		return false
+	}
+	calendars := models.SelectCalendars(job.CalendarIds)
+	reason, _ := models.ExcludeByCalendars(calendars, fireTime, job.Timezone)
+	if "" == reason {
+		return false
+	}
+
+	ctx := newScheduleContext(job, scheduleType, fireTime.Unix())
+	if models.CalendarActionDefer != job.GetCalendarAction() {
+		logs.Infof("Job(%s)跳过执行：%s", job.Name, reason)
+		ctx.skipped(reason)
+		return true
+	}
+
+	deferTo := models.NextIncludedTime(calendars, fireTime, job.Timezone)
+	if deferTo.IsZero() {
+		ctx.skipped(fmt.Sprintf("%s，且找不到可以顺延的时间点", reason))
+		return true
+	}
+
+	deferredExecutionsLock.Lock()
+	defer deferredExecutionsLock.Unlock()
+
+	if _, exist := deferredExecutions[job.Id]; exist {
+		ctx.skipped(fmt.Sprintf("%s，已有等待顺延的执行", reason))
+		return true
+	}
+	detail := fmt.Sprintf("触发时间点%s被排除(%s)，顺延至%s执行",
+		dateutil.Layout(fireTime, dateutil.DayTimeSecondFormatter), reason, dateutil.Layout(deferTo, dateutil.DayTimeSecondFormatter))
+	logs.Infof("Job(%s)%s", job.Name, detail)
+	deferredExecutions[job.Id] = time.AfterFunc(time.Until(deferTo), func() {
+		this.runDeferred(job.Id, scheduleType, detail)
+	})
+	return true
+}
+
+// 执行顺延的调度
+func (this *HttpTask) runDeferred(jobId uint64, scheduleType int, detail string) {
+	deferredExecutionsLock.Lock()
+	delete(deferredExecutions, jobId)
+	deferredExecutionsLock.Unlock()
+
+	if !IsStandaloneOrLeader() {
+		return
+	}
+	job, err := models.GetJob(jobId)
+	if err != nil || models.JobStatusOk != job.Status {
+		return
+	}
+
+	ctx := newScheduleContext(job, scheduleType, time.Now().Unix())
+	ctx.detail(detail)
+	this.doRun(ctx)
+}
+
+// 取消所有等待顺延的执行
+func clearDeferredExecutions() {
+	deferredExecutionsLock.Lock()
+	defer deferredExecutionsLock.Unlock()
+
+	for jobId, timer := range deferredExecutions {
+		timer.Stop()
+		delete(deferredExecutions, jobId)
+	}
+}
+
+// 去掉被作业引用的日历排除的触发时间点
+func filterCalendarTimes(job *models.Job, fireTimes []int64) []int64 {
+	if len(job.CalendarIds) == 0 {
+		return fireTimes
+	}
+	calendars := models.SelectCalendars(job.CalendarIds)
+	filtered := make([]int64, 0, len(fireTimes))
+	for _, fireTime := range fireTimes {
+		if reason, _ := models.ExcludeByCalendars(calendars, time.Unix(fireTime, 0), job.Timezone); "" != reason {
+			logs.Infof("Job(%s)错过的执行时间点%s不需要补偿：%s", job.Name, formatFireTime(job, fireTime), reason)
+			continue
+		}
+		filtered = append(filtered, fireTime)
+	}
+	return filtered
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gojob/models"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/pkg/errors"
+)
+
+// 执行中的调度
+type ActiveExecution struct {
+	Id           string `json:"id"`           // 执行ID，即调度跟踪主键
+	JobId        string `json:"jobId"`        // JOB主键
+	JobName      string `json:"jobName"`      // JOB名称
+	ScheduleType int    `json:"scheduleType"` // 调度类型
+	StartTime    int64  `json:"startTime"`    // 开始时间
+	Async        bool   `json:"async"`        // 是否在等待异步回调
+}
+
+var activeExecutions = make(map[uint64]*scheduleContext)
+var activeExecutionsLock sync.Mutex
+
+// 根据作业的并发策略登记执行，返回false表示本次执行应当跳过
+func acquireExecution(ctx *scheduleContext) (bool, string) {
+	activeExecutionsLock.Lock()
+	actives := make([]*scheduleContext, 0)
+	for _, v := range activeExecutions {
+		if v.job.Id == ctx.job.Id {
+			actives = append(actives, v)
+		}
+	}
+
+	replaced := make([]*scheduleContext, 0)
+	if len(actives) > 0 {
+		switch ctx.job.GetConcurrencyPolicy() {
+		case models.ConcurrencyPolicyForbid:
+			activeExecutionsLock.Unlock()
+			return false, fmt.Sprintf("并发策略为禁止，存在%d个未结束的执行", len(actives))
+		case models.ConcurrencyPolicyReplace:
+			for _, v := range actives {
+				delete(activeExecutions, v.traceId)
+				replaced = append(replaced, v)
+			}
+		default:
+			if ctx.job.MaxConcurrency > 0 && len(actives) >= ctx.job.MaxConcurrency {
+				activeExecutionsLock.Unlock()
+				return false, fmt.Sprintf("未结束的执行数量达到上限：%d", ctx.job.MaxConcurrency)
+			}
+		}
+	}
+	activeExecutions[ctx.traceId] = ctx
+	activeExecutionsLock.Unlock()
+
+	for _, v := range replaced {
+		logs.Infof("Job(%s)的执行(%v)被新的执行替换", v.job.Name, v.traceId)
+		ctx.detail(fmt.Sprintf("取消未结束的执行：%v", v.traceId))
+		v.cancel(fmt.Sprintf("被新的执行(%v)替换", ctx.traceId))
+	}
+	return true, ""
+}
+
+// 取消执行中的调度
+func CancelExecution(traceId uint64, reason string) error {
+	activeExecutionsLock.Lock()
+	ctx, exist := activeExecutions[traceId]
+	activeExecutionsLock.Unlock()
+
+	if !exist {
+		return errors.Errorf("执行(%v)不存在或者已经结束", traceId)
+	}
+	logs.Infof("Job(%s)的执行(%v)被取消：%s", ctx.job.Name, traceId, reason)
+	ctx.cancel(reason)
+	return nil
+}
+
+// 执行结束，移除登记
+func releaseExecution(ctx *scheduleContext) {
+	activeExecutionsLock.Lock()
+	defer activeExecutionsLock.Unlock()
+
+	delete(activeExecutions, ctx.traceId)
+}
+
+// 获取执行中的调度列表
+func GetActiveExecutions(jobId uint64) []*ActiveExecution {
+	activeExecutionsLock.Lock()
+	defer activeExecutionsLock.Unlock()
+
+	list := make([]*ActiveExecution, 0)
+	for _, v := range activeExecutions {
+		if 0 != jobId && v.job.Id != jobId {
+			continue
+		}
+		list = append(list, &ActiveExecution{
+			Id:           stringutil.UintToStr(v.traceId),
+			JobId:        stringutil.UintToStr(v.job.Id),
+			JobName:      v.job.Name,
+			ScheduleType: v.scheduleType,
+			StartTime:    v.startTime,
+			Async:        v.running,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime < list[j].StartTime
+	})
+	return list
+}
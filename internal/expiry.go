@@ -0,0 +1,87 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/logs"
+)
+
+// 等待到达有效期截止时间的作业
+var expiryTimers = make(map[uint64]*time.Timer)
+var expiryTimersLock sync.Mutex
+
+// 作业设置了有效期截止时间时，到期后自动标记为已失效；已过截止时间的立即标记
+func armExpiry(job *models.Job) {
+	disarmExpiry(job.Id)
+	if job.EffectiveUntil <= 0 {
+		return
+	}
+
+	expiryTimersLock.Lock()
+	defer expiryTimersLock.Unlock()
+
+	jobId := job.Id
+	// 截止时间点仍可以触发，过了截止时间才失效
+	expiryTimers[jobId] = time.AfterFunc(time.Until(time.Unix(job.EffectiveUntil+1, 0)), func() {
+		expireJob(jobId)
+	})
+}
+
+func disarmExpiry(jobId uint64) {
+	expiryTimersLock.Lock()
+	defer expiryTimersLock.Unlock()
+
+	if timer, exist := expiryTimers[jobId]; exist {
+		timer.Stop()
+		delete(expiryTimers, jobId)
+	}
+}
+
+func clearExpiries() {
+	expiryTimersLock.Lock()
+	defer expiryTimersLock.Unlock()
+
+	for jobId, timer := range expiryTimers {
+		timer.Stop()
+		delete(expiryTimers, jobId)
+	}
+}
+
+// 将超过有效期截止时间的作业标记为已失效
+func expireJob(jobId uint64) {
+	expiryTimersLock.Lock()
+	delete(expiryTimers, jobId)
+	expiryTimersLock.Unlock()
+
+	if !IsStandaloneOrLeader() {
+		return
+	}
+	job, err := models.GetJob(jobId)
+	if err != nil || models.JobStatusOk != job.Status || !job.IsExpiredAt(time.Now().Unix()) {
+		return
+	}
+	if err := UpdateJobStatus(jobId, models.JobStatusExpired); nil != err {
+		logs.Errorf("Job(%s)标记为已失效失败：%s", job.Name, err.Error())
+		return
+	}
+	logs.Infof("Job(%s)超过有效期截止时间，已失效", job.Name)
+}
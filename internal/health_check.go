@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/logs"
+)
+
+const (
+	// 默认探活间隔（秒）
+	defaultHealthCheckInterval = 10
+	// 探活请求超时时间
+	healthCheckTimeout = 3 * time.Second
+)
+
+var healthCheckClient = &http.Client{Timeout: healthCheckTimeout}
+
+// 执行节点主动探活任务，对设置了探活URI的作业，定期GET其在线执行节点的探活地址，结果计入熔断器；
+// 只在单机或主节点上执行
+func StartHealthCheckTask(interval int) {
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	go func(ticker *time.Ticker) {
+		for {
+			<-ticker.C
+			if IsStandaloneOrLeader() {
+				checkExecutorHealth()
+			}
+		}
+	}(ticker)
+}
+
+func checkExecutorHealth() {
+	jobs, err := models.ForEachJob()
+	if err != nil {
+		logs.Errorf("执行节点探活，查询任务列表失败：%s", err.Error())
+		return
+	}
+
+	// 同一个探活地址只探测一次
+	probes := make(map[string]string)
+	for _, job := range jobs {
+		if models.JobStatusOk != job.Status || "" == job.HealthCheckUri {
+			continue
+		}
+		for _, executor := range jobExecutors(job) {
+			probes[healthCheckUrl(job, executor.Address)] = executor.Address
+		}
+	}
+
+	var wg sync.WaitGroup
+	for probeUrl, address := range probes {
+		wg.Add(1)
+		go func(probeUrl string, address string) {
+			defer wg.Done()
+			res, err := healthCheckClient.Get(probeUrl)
+			if nil != err {
+				reportExecutorResult(address, false, fmt.Sprintf("探活请求错误：%s", err.Error()))
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				reportExecutorResult(address, false, fmt.Sprintf("探活StatusCode：%v", res.StatusCode))
+				return
+			}
+			reportExecutorResult(address, true, "")
+		}(probeUrl, address)
+	}
+	wg.Wait()
+}
+
+func healthCheckUrl(job *models.Job, address string) string {
+	probeUrl := job.Protocol + "://" + address
+	if strings.HasPrefix(job.HealthCheckUri, "/") {
+		return probeUrl + job.HealthCheckUri
+	}
+	return probeUrl + "/" + job.HealthCheckUri
+}
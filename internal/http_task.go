@@ -18,21 +18,32 @@
 package internal
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"gojob/internal/bl"
 	"gojob/models"
 	"gojob/util/dateutil"
 	"gojob/util/httputil"
+	"gojob/util/jsonutil"
 	"gojob/util/logs"
 	"gojob/util/stringutil"
 	"gojob/util/syncutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	responseBodyReadLimit  = 1 << 20 // 读取响应体的最大字节数
+	responseBodyTraceLimit = 500     // 调度跟踪中保存的响应体最大字节数
 )
 
 // 执行节点
@@ -40,6 +51,17 @@ type executeNode struct {
 	address   string // 访问地址
 	parameter string // 参数
 	weight    int
+	shard     *models.ShardTrace // 分片执行记录，仅分片执行时存在
+}
+
+// 请求体模板数据
+type requestBodyData struct {
+	JobId        string            // JOB主键
+	JobName      string            // JOB名称
+	Sharding     string            // 分片参数
+	Params       map[string]string // http参数
+	ScheduleType int               // 调度类型
+	Timestamp    int64             // 调度时间戳（秒）
 }
 
 // HTTP任务
@@ -53,12 +75,8 @@ func newTask(job *models.Job) *HttpTask {
 	retryConditionResponseNil := func(res *http.Response) bool {
 		return res == nil
 	}
-	retryConditionStatusNot200 := func(res *http.Response) bool {
-		return res.StatusCode != 200
-	}
 	client := httputil.NewHttpClient().
-		AddRetryCondition(retryConditionResponseNil).
-		AddRetryCondition(retryConditionStatusNot200)
+		AddRetryCondition(retryConditionResponseNil)
 	return &HttpTask{
 		jobId:      job.Id,
 		httpClient: client,
@@ -66,60 +84,70 @@ func newTask(job *models.Job) *HttpTask {
 }
 
 func (this *HttpTask) Run() {
-	if IsStandaloneOrLeader() {
-		job, err := models.GetJob(this.jobId)
-		if err != nil {
-			logs.Errorf("任务调度失败,查找Job信息错误:%s", err.Error())
-			return
-		}
+	if run := this.Dispatch(); nil != run {
+		run()
+	}
+}
 
-		sch, exist := getScheduler(this.jobId)
-		if !exist {
-			logs.Errorf("任务调度失败,未找到Job(%v)的调度器:%s", this.jobId)
-			return
-		}
+// 在调度引擎的工作协程中完成触发记录和日历判断，HTTP请求、重试和故障转移交给返回的函数在执行协程中运行
+func (this *HttpTask) Dispatch() func() {
+	if !IsStandaloneOrLeader() {
+		return nil
+	}
+	job, err := models.GetJob(this.jobId)
+	if err != nil {
+		logs.Errorf("任务调度失败,查找Job信息错误:%s", err.Error())
+		return nil
+	}
 
-		startTime := time.Now().Unix()
-		nextTime := sch.GetNextTime()
+	sch, exist := getScheduler(this.jobId)
+	if !exist {
+		logs.Errorf("任务调度失败,未找到Job(%v)的调度器:%s", this.jobId)
+		return nil
+	}
 
-		updateTriggered(this.jobId, startTime, nextTime)
+	startTime := time.Now().Unix()
+	nextTime := sch.GetNextTime()
 
-		ctx := &scheduleContext{
-			job:          job,
-			scheduleType: models.ScheduleTypeAuto,
-			startTime:    startTime,
-			details:      make([]string, 0),
-		}
-		this.doRun(ctx)
+	updateTriggered(this.jobId, startTime, nextTime)
 
-		scanMisfires()
+	excluded := this.applyCalendars(job, time.Unix(startTime, 0), models.ScheduleTypeAuto)
+	scanMisfires()
+	if excluded {
+		return nil
+	}
+	ctx := newScheduleContext(job, models.ScheduleTypeAuto, startTime)
+	return func() {
+		this.doRun(ctx)
 	}
 }
 
 // http任务run
 func (this *HttpTask) doRun(ctx *scheduleContext) {
-	executeNodes := make([]*executeNode, 0)
-	if len(ctx.job.Executors) > 0 {
-		for _, v := range ctx.job.Executors {
-			if models.ExecutorStatusOk == v.Status {
-				executeNodes = append(executeNodes, &executeNode{
-					address: v.Address,
-					weight:  v.Weight,
-				})
-			}
-		}
-	}
-	if len(executeNodes) == 0 {
-		logs.Errorf("任务调度失败，Job(%s)无执行节点", ctx.job.Name)
-		ctx.failed("无执行节点")
+	if acquired, reason := acquireExecution(ctx); !acquired {
+		logs.Infof("Job(%s)跳过执行：%s", ctx.job.Name, reason)
+		ctx.skipped(reason)
 		return
 	}
-	if IsClusterMode() {
-		ctx.detail(fmt.Sprintf("调度节点：%s - %s", GetLeaderId(), GetLeaderServerAddress()))
+	ctx.startDeadline()
+	if ctx.job.IsAsync() {
+		prepareAsyncExecution(ctx)
+	}
+
+	executeNodes, ok := this.prepareExecuteNodes(ctx)
+	if !ok {
+		return
 	}
 	ctx.detail(fmt.Sprintf("执行节点数量：%d，执行节点选择策略：%s", len(executeNodes), ctx.job.ExecutorSelectStrategy))
-	if models.ExecutorSelectStrategySharding == ctx.job.ExecutorSelectStrategy {
-		shardingResults := this.shardingExecutors(ctx, executeNodes)
+	if models.ExecutorSelectStrategyBroadcast == ctx.job.ExecutorSelectStrategy {
+		this.broadcast(ctx, executeNodes)
+	} else if models.ExecutorSelectStrategySharding == ctx.job.ExecutorSelectStrategy {
+		shardingResults, err := this.shardingExecutors(ctx, executeNodes)
+		if nil != err {
+			logs.Errorf("任务调度失败，Job(%s)获取分片列表错误：%s", ctx.job.Name, err.Error())
+			ctx.failed(fmt.Sprintf("分片来源错误：%s", err.Error()))
+			return
+		}
 		if len(shardingResults) == 0 {
 			ctx.failed("执行节点分片错误")
 			return
@@ -128,10 +156,10 @@ func (this *HttpTask) doRun(ctx *scheduleContext) {
 		var wg sync.WaitGroup
 		failedNodes := syncutil.NewMutexSlice()
 		for _, shardingResult := range shardingResults {
+			shardingResult.shard = ctx.newShard(shardingResult.parameter)
 			wg.Add(1)
 			go func(exeNode *executeNode) {
-				url := this.buildRequestUrl(ctx, exeNode)
-				succeed := this.doExecute(ctx, url)
+				succeed := this.executeShard(ctx, exeNode)
 				if !succeed {
 					failedNodes.Add(exeNode)
 				}
@@ -140,31 +168,157 @@ func (this *HttpTask) doRun(ctx *scheduleContext) {
 		}
 		wg.Wait()
 
-		takeoverSucceed := true
-		if models.FailTakeoverEnabled == ctx.job.FailTakeover && failedNodes.Size() > 0 && len(executeNodes) > failedNodes.Size() {
+		takeoverSucceed := failedNodes.Size() == 0
+		if models.FailTakeoverEnabled == ctx.job.FailTakeover && failedNodes.Size() > 0 && len(executeNodes) > failedNodes.Size() && !ctx.stopped() {
 			takeoverSucceed = this.shardingTakeover(ctx, executeNodes, failedNodes)
 		}
 		if takeoverSucceed {
-			ctx.succeed()
+			this.complete(ctx, len(shardingResults), len(shardingResults))
 		} else {
-			ctx.failed("执行失败")
+			ctx.dispatchFailed()
 		}
 	} else { // 非分片执行
 		selected := this.selectExecutor(ctx, executeNodes)
+		if nil == selected {
+			logs.Errorf("任务调度失败，Job(%s)未选中执行节点，执行节点选择策略：%s", ctx.job.Name, ctx.job.ExecutorSelectStrategy)
+			ctx.detail(fmt.Sprintf("未选中执行节点，执行节点选择策略：%s", ctx.job.ExecutorSelectStrategy))
+			ctx.dispatchFailed()
+			return
+		}
 		ctx.detail(fmt.Sprintf("选中执行节点：%s", selected.address))
-		executeUrl := this.buildRequestUrl(ctx, selected)
-		succeed := this.doExecute(ctx, executeUrl)
-		if models.FailTakeoverEnabled == ctx.job.FailTakeover && !succeed && len(executeNodes) > 1 {
+		succeed := this.doExecute(ctx, selected)
+		if models.FailTakeoverEnabled == ctx.job.FailTakeover && !succeed && len(executeNodes) > 1 && !ctx.stopped() {
 			succeed = this.standaloneTakeover(ctx, selected, executeNodes)
 		}
 		if succeed {
-			ctx.succeed()
+			this.complete(ctx, 1, 1)
 		} else {
-			ctx.failed("执行失败")
+			ctx.dispatchFailed()
 		}
 	}
 }
 
+// 解析作业的执行节点，过滤标签不匹配和已熔断的执行节点；没有可用的执行节点时记录调度失败
+func (this *HttpTask) prepareExecuteNodes(ctx *scheduleContext) ([]*executeNode, bool) {
+	executors, err := this.selectByLabels(ctx, jobExecutors(ctx.job))
+	if nil != err {
+		logs.Errorf("任务调度失败，Job(%s)标签选择器错误：%s", ctx.job.Name, err.Error())
+		ctx.failed(fmt.Sprintf("标签选择器错误：%s", err.Error()))
+		return nil, false
+	}
+	executeNodes := make([]*executeNode, 0)
+	for _, v := range executors {
+		executeNodes = append(executeNodes, &executeNode{
+			address: v.Address,
+			weight:  v.Weight,
+		})
+	}
+	if len(executeNodes) == 0 {
+		logs.Errorf("任务调度失败，Job(%s)无执行节点", ctx.job.Name)
+		ctx.failed("无执行节点")
+		return nil, false
+	}
+	executeNodes = this.filterBreakers(ctx, executeNodes)
+	if len(executeNodes) == 0 {
+		logs.Errorf("任务调度失败，Job(%s)的执行节点均已熔断", ctx.job.Name)
+		ctx.failed("无可用执行节点，执行节点均已熔断")
+		return nil, false
+	}
+	if IsClusterMode() {
+		ctx.detail(fmt.Sprintf("调度节点：%s - %s", GetLeaderId(), GetLeaderServerAddress()))
+	}
+	return executeNodes, true
+}
+
+// 按作业的标签选择器过滤执行节点，并记录匹配的执行节点
+func (this *HttpTask) selectByLabels(ctx *scheduleContext, executors []*models.Executor) ([]*models.Executor, error) {
+	if "" == strings.TrimSpace(ctx.job.ExecutorSelector) {
+		return executors, nil
+	}
+	selector, err := models.ParseLabelSelector(ctx.job.ExecutorSelector)
+	if nil != err {
+		return nil, err
+	}
+	matched := make([]*models.Executor, 0, len(executors))
+	for _, executor := range executors {
+		if selector.Matches(executor.Labels) {
+			matched = append(matched, executor)
+		}
+	}
+	ctx.detail(fmt.Sprintf("标签选择器：%s，匹配执行节点：%d/%d", ctx.job.ExecutorSelector, len(matched), len(executors)))
+	for _, executor := range matched {
+		ctx.detail(fmt.Sprintf("标签匹配：%s", executor.Address))
+	}
+	return matched, nil
+}
+
+// 跳过已熔断的执行节点
+func (this *HttpTask) filterBreakers(ctx *scheduleContext, executeNodes []*executeNode) []*executeNode {
+	availables := make([]*executeNode, 0, len(executeNodes))
+	for _, node := range executeNodes {
+		if executorAvailable(node.address) {
+			availables = append(availables, node)
+		} else {
+			ctx.detail(fmt.Sprintf("执行节点已熔断，跳过：%s", node.address))
+		}
+	}
+	return availables
+}
+
+// 广播执行，并行调用所有执行节点，按广播成功规则判定结果
+func (this *HttpTask) broadcast(ctx *scheduleContext, executeNodes []*executeNode) {
+	required := broadcastRequired(ctx.job.GetBroadcastSuccessRule(), len(executeNodes))
+	ctx.detail(fmt.Sprintf("广播执行，成功规则：%s，需要%d个执行节点成功", ctx.job.GetBroadcastSuccessRule(), required))
+
+	results := make([]bool, len(executeNodes))
+	var wg sync.WaitGroup
+	for i, node := range executeNodes {
+		wg.Add(1)
+		go func(index int, exeNode *executeNode) {
+			results[index] = this.doExecute(ctx, exeNode)
+			wg.Done()
+		}(i, node)
+	}
+	wg.Wait()
+
+	succeedCount := 0
+	for i, node := range executeNodes {
+		if results[i] {
+			succeedCount++
+		}
+		ctx.detail(fmt.Sprintf("广播结果：%s - %s", node.address, succeedText(results[i])))
+	}
+	ctx.detail(fmt.Sprintf("广播完成，成功%d个，失败%d个", succeedCount, len(executeNodes)-succeedCount))
+
+	if succeedCount < required {
+		ctx.failed(fmt.Sprintf("执行失败，成功的执行节点数量%d少于%d", succeedCount, required))
+		return
+	}
+	this.complete(ctx, succeedCount, required)
+}
+
+// 按广播成功规则计算需要成功的执行节点数量
+func broadcastRequired(rule string, total int) int {
+	switch rule {
+	case models.BroadcastSuccessRuleOne:
+		return 1
+	case models.BroadcastSuccessRuleQuorum:
+		return total/2 + 1
+	}
+	return total
+}
+
+// 执行节点请求成功；异步模式下需等待expected个执行节点回调，其中至少required个成功
+func (this *HttpTask) complete(ctx *scheduleContext, expected int, required int) {
+	if !ctx.job.IsAsync() {
+		ctx.succeed()
+		return
+	}
+	ctx.detail(fmt.Sprintf("执行节点已确认，等待%d个回调", expected))
+	deadline := time.Unix(ctx.startTime, 0).Add(time.Duration(ctx.job.GetAsyncTimeout()) * time.Second)
+	awaitAsyncExecution(ctx, expected, required, deadline)
+}
+
 func (this *HttpTask) buildRequestUrl(ctx *scheduleContext, executeNode *executeNode) string {
 	base := ctx.job.Protocol + "://" + executeNode.address
 	if strings.HasPrefix(ctx.job.Uri, "/") {
@@ -174,7 +328,7 @@ func (this *HttpTask) buildRequestUrl(ctx *scheduleContext, executeNode *execute
 	}
 
 	params := stringutil.KVsToMap(ctx.job.HttpParam, "|")
-	if ctx.job.ShardingCount > 0 && "" != executeNode.parameter {
+	if "" != executeNode.parameter {
 		params["sharding"] = executeNode.parameter
 	}
 	executeUrl := stringutil.BuildQueryString(base, params)
@@ -182,18 +336,29 @@ func (this *HttpTask) buildRequestUrl(ctx *scheduleContext, executeNode *execute
 }
 
 // 根据分片策进行执行器分片
-func (this *HttpTask) shardingExecutors(ctx *scheduleContext, executeNodes []*executeNode) []*executeNode {
-	ctx.detail(fmt.Sprintf("分片数量:%d", ctx.job.ShardingCount))
-	var params []string
-	if ctx.job.ShardingParam != "" {
-		params = strings.Split(ctx.job.ShardingParam, ",")
-	} else {
-		params = make([]string, ctx.job.ShardingCount)
-		for i := 0; i < ctx.job.ShardingCount; i++ {
-			params[i] = strconv.Itoa(i)
+func (this *HttpTask) shardingExecutors(ctx *scheduleContext, executeNodes []*executeNode) ([]*executeNode, error) {
+	params, err := this.resolveShardParams(ctx)
+	if nil != err {
+		return nil, err
+	}
+	ctx.detail(fmt.Sprintf("分片数量:%d，分片策略：%s", len(params), ctx.job.GetShardingStrategy()))
+	var sharding [][]int
+	switch ctx.job.GetShardingStrategy() {
+	case models.ShardingStrategyWeight:
+		weights := make([]int, len(executeNodes))
+		for i, node := range executeNodes {
+			weights[i] = node.weight
+		}
+		sharding = bl.WeightSharding(len(params), weights)
+	case models.ShardingStrategySticky:
+		keys := make([]string, len(executeNodes))
+		for i, node := range executeNodes {
+			keys[i] = node.address
 		}
+		sharding = bl.StickySharding(params, keys)
+	default:
+		sharding = bl.Sharding(len(params), len(executeNodes))
 	}
-	sharding := bl.Sharding(len(params), len(executeNodes))
 
 	shardingResults := make([]*executeNode, 0)
 	for i, v := range sharding {
@@ -212,47 +377,230 @@ func (this *HttpTask) shardingExecutors(ctx *scheduleContext, executeNodes []*ex
 		}
 	}
 
-	return shardingResults
+	return shardingResults, nil
 }
 
-func (this *HttpTask) doExecute(ctx *scheduleContext, doUrl string) bool {
-	ctx.mutexDetail(fmt.Sprintf("开始执行HTTP请求，URL为：%s", doUrl))
+// 根据请求体模板生成请求体
+func (this *HttpTask) buildRequestBody(ctx *scheduleContext, executeNode *executeNode) (string, error) {
+	if "" == ctx.job.HttpBody || models.HttpMethodGet == ctx.job.GetHttpMethod() {
+		return "", nil
+	}
+	tpl, err := template.New("body").Parse(ctx.job.HttpBody)
+	if err != nil {
+		return "", err
+	}
+	data := &requestBodyData{
+		JobId:        stringutil.UintToStr(ctx.job.Id),
+		JobName:      ctx.job.Name,
+		Params:       stringutil.KVsToMap(ctx.job.HttpParam, "|"),
+		ScheduleType: ctx.scheduleType,
+		Timestamp:    time.Now().Unix(),
+	}
+	if "" != executeNode.parameter {
+		data.Sharding = executeNode.parameter
+	}
+	var buffer bytes.Buffer
+	if err := tpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (this *HttpTask) doExecute(ctx *scheduleContext, executeNode *executeNode) bool {
+	doUrl := this.buildRequestUrl(ctx, executeNode)
+	method := ctx.job.GetHttpMethod()
+	body, err := this.buildRequestBody(ctx, executeNode)
+	if nil != err {
+		logs.Errorf("Job(%s) 请求体模板错误：%s", ctx.job.Name, err.Error())
+		ctx.mutexDetail(fmt.Sprintf("请求体模板错误：%s", err.Error()))
+		return false
+	}
+	ctx.mutexDetail(fmt.Sprintf("开始执行HTTP请求，%s %s", method, doUrl))
 
-	this.httpClient.SetTimeout(ctx.job.Timeout).
-		SetRetryCount(ctx.job.RetryCount).
-		SetRetryWaitTime(ctx.job.RetryWaitTime)
-	request := this.httpClient.NewRequest()
+	this.httpClient.SetTimeout(ctx.job.Timeout)
+	requestCtx, cancelRequest := ctx.requestContext()
+	defer cancelRequest()
+	// 执行节点统计按每次请求尝试记录，不计入限流等待和重试间隔
+	var finalAttempt *httputil.Attempt
+	request := this.httpClient.NewRequest().
+		SetContext(requestCtx).
+		SetRetryPolicy(GetRetryPolicy(ctx.job)).
+		SetAttemptListener(func(attempt *httputil.Attempt) {
+			ctx.mutexDetail(attemptText(executeNode.address, attempt))
+			if attempt.Retry || nil != attempt.Err {
+				bl.EndRequest(executeNode.address, attempt.Elapsed, false)
+				return
+			}
+			finalAttempt = attempt
+		})
 	if "" != ctx.job.HttpHeaderParam {
 		params := stringutil.KVsToMap(ctx.job.HttpHeaderParam, "|")
 		for k, v := range params {
 			request.AddHeader(k, v)
 		}
 	}
+	if "" != body {
+		request.SetContentType(ctx.job.GetHttpContentType())
+	}
+	if ctx.job.IsAsync() {
+		request.AddHeader("X-Execution-Id", stringutil.UintToStr(ctx.traceId))
+		request.AddHeader("X-Callback-Uri", fmt.Sprintf(asyncCallbackUriFormat, ctx.traceId))
+	}
 	if models.HttpSignEnabled == ctx.job.HttpSign {
 		requestUrl, _ := url.Parse(doUrl)
 		timestamp := strconv.FormatInt(dateutil.NowMillisecond(), 10)
-		base := requestUrl.RequestURI() + timestamp
+		base := requestUrl.RequestURI() + timestamp + body
 		ctx.mutexDetail(fmt.Sprintf("开始数字签名，被签名字符串为：%s", base))
 		sign := Signature(base)
 		request.AddHeader("X-Timestamp", timestamp)
 		request.AddHeader("X-Sign", sign)
 	}
-	res, err := request.Get(doUrl)
+	request.AddRetryCondition(func(res *http.Response) bool {
+		if nil == res {
+			return false
+		}
+		succeed, _ := checkResponse(ctx.job, res)
+		return !succeed
+	})
+	permit, throttled := acquireDispatch(requestCtx, ctx.job, executeNode.address)
+	if "" != throttled {
+		logs.Warnf("Job(%s) 请求被限流：%s", ctx.job.Name, throttled)
+		ctx.mutexThrottled(throttled)
+		return false
+	}
+	defer permit.release()
+	if throttled = permit.take(requestCtx); "" != throttled {
+		logs.Warnf("Job(%s) 请求被限流：%s", ctx.job.Name, throttled)
+		ctx.mutexThrottled(throttled)
+		return false
+	}
+	// 重试同样受每秒请求数限制
+	request.SetAttemptGate(func(number int) error {
+		if number > 1 {
+			if throttled = permit.take(requestCtx); "" != throttled {
+				return errors.New(throttled)
+			}
+		}
+		bl.BeginRequest(executeNode.address)
+		return nil
+	})
+	ctx.mutexDispatched(executeNode.address)
+	res, err := request.Do(method, doUrl, []byte(body))
+	if nil != err && "" != throttled {
+		logs.Warnf("Job(%s) 请求被限流：%s", ctx.job.Name, throttled)
+		ctx.mutexThrottled(throttled)
+		return false
+	}
 	if nil != err {
+		reportExecutorResult(executeNode.address, false, fmt.Sprintf("HTTP请求错误：%s", err.Error()))
 		logs.Errorf("Job(%s) HTTP请求错误：%s", ctx.job.Name, err.Error())
 		ctx.mutexDetail(fmt.Sprintf("HTTP请求错误：%s", err.Error()))
 		return false
 	}
 	defer res.Body.Close()
-	if 200 != res.StatusCode {
-		logs.Errorf("Job(%s) HTTP请求错误StatusCode：%v", ctx.job.Name, res.StatusCode)
-		ctx.mutexDetail(fmt.Sprintf("HTTP请求错误StatusCode：%v", res.StatusCode))
+	succeed, reason := checkResponse(ctx.job, res)
+	responseBody, _ := httputil.ReadBody(res, responseBodyReadLimit)
+	bl.EndRequest(executeNode.address, finalAttempt.Elapsed, succeed)
+	// 只有5xx计为执行节点故障，业务判定失败不触发熔断
+	reportExecutorResult(executeNode.address, res.StatusCode < 500, fmt.Sprintf("StatusCode：%v", res.StatusCode))
+	ctx.mutexResponseBody(executeNode, string(responseBody), succeed)
+	if !succeed {
+		logs.Errorf("Job(%s) HTTP请求失败：%s", ctx.job.Name, reason)
+		ctx.mutexDetail(fmt.Sprintf("HTTP请求失败：%s", reason))
 		return false
 	}
 	ctx.mutexDetail("HTTP请求成功")
 	return true
 }
 
+// 获取作业的重试策略
+func GetRetryPolicy(job *models.Job) *httputil.RetryPolicy {
+	policy := &httputil.RetryPolicy{
+		RetryCount:      job.RetryCount,
+		InitialInterval: time.Duration(job.RetryWaitTime) * time.Second,
+		MaxInterval:     time.Duration(job.RetryMaxWaitTime) * time.Second,
+		Jitter:          models.RetryJitterEnabled == job.RetryJitter,
+		StatusCodes:     splitList(job.RetryStatusCodes),
+		ErrorClasses:    splitList(job.RetryErrorClasses),
+	}
+	if models.RetryBackoffExponential == job.GetRetryBackoff() {
+		policy.Multiplier = 2
+		if policy.InitialInterval <= 0 {
+			policy.InitialInterval = time.Second
+		}
+	}
+	return policy
+}
+
+// 按逗号拆分列表，忽略空白项
+func splitList(str string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); "" != item {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// 请求尝试的调度跟踪记录
+func attemptText(address string, attempt *httputil.Attempt) string {
+	var outcome string
+	if nil != attempt.Err {
+		outcome = fmt.Sprintf("请求错误(%s)", httputil.ErrorClass(attempt.Err))
+	} else {
+		outcome = fmt.Sprintf("StatusCode：%v", attempt.Response.StatusCode)
+	}
+	text := fmt.Sprintf("第%d次请求%s，%s，耗时%d毫秒", attempt.Number, address, outcome, attempt.Elapsed/time.Millisecond)
+	if attempt.Retry {
+		text = text + fmt.Sprintf("，%d毫秒后重试", attempt.Wait/time.Millisecond)
+	}
+	return text
+}
+
+// 根据作业的成功判定规则检查响应，返回是否成功以及失败原因
+func checkResponse(job *models.Job, res *http.Response) (bool, string) {
+	codes := strings.Split(job.SuccessStatusCodes, ",")
+	accepted := false
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if ("" == code && 200 == res.StatusCode) || code == strconv.Itoa(res.StatusCode) {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return false, fmt.Sprintf("StatusCode：%v", res.StatusCode)
+	}
+
+	if "" == job.SuccessJsonPath && "" == job.SuccessRegex {
+		return true, ""
+	}
+	body, err := httputil.ReadBody(res, responseBodyReadLimit)
+	if err != nil {
+		return false, fmt.Sprintf("读取响应体错误：%s", err.Error())
+	}
+	if "" != job.SuccessJsonPath {
+		value, err := jsonutil.GetPathValue(body, job.SuccessJsonPath)
+		if err != nil {
+			return false, fmt.Sprintf("解析响应体错误：%s", err.Error())
+		}
+		if value != job.SuccessJsonValue {
+			return false, fmt.Sprintf("响应体%s的值为：%s，期望值为：%s", job.SuccessJsonPath, value, job.SuccessJsonValue)
+		}
+	}
+	if "" != job.SuccessRegex {
+		matched, err := regexp.Match(job.SuccessRegex, body)
+		if err != nil {
+			return false, fmt.Sprintf("正则表达式错误：%s", err.Error())
+		}
+		if !matched {
+			return false, fmt.Sprintf("响应体不匹配正则表达式：%s", job.SuccessRegex)
+		}
+	}
+	return true, ""
+}
+
 // 根据策略选择执行器
 func (this *HttpTask) selectExecutor(ctx *scheduleContext, executeNodes []*executeNode) *executeNode {
 	weightItems := make([]bl.LoadItem, len(executeNodes))
@@ -261,22 +609,27 @@ func (this *HttpTask) selectExecutor(ctx *scheduleContext, executeNodes []*execu
 		weightItems[i] = bl.LoadItem{
 			Index:  i,
 			Weight: v.weight,
+			Key:    v.address,
 		}
 	}
 	switch ctx.job.ExecutorSelectStrategy {
 	case models.ExecutorSelectStrategyRandom:
 		selected = randomLoadBalance.DoSelect(weightItems)
-	case models.ExecutorSelectStrategyRound:
-		lb, exist := roundLoadBalances[ctx.job.Id]
+	case models.ExecutorSelectStrategyRound, models.ExecutorSelectStrategyWeightRandom, models.ExecutorSelectStrategyWeightRound:
+		lb, exist := getLoadBalance(ctx.job.ExecutorSelectStrategy, ctx.job.Id)
 		if exist {
 			selected = lb.DoSelect(weightItems)
 		}
-	case models.ExecutorSelectStrategyWeightRandom:
-		selected = weightRandomLoadBalance.DoSelect(weightItems)
-	case models.ExecutorSelectStrategyWeightRound:
-		lb, exist := weightRoundLoadBalances[ctx.job.Id]
+	case models.ExecutorSelectStrategyLeastActive:
+		selected = leastActiveLoadBalance.DoSelect(weightItems)
+	case models.ExecutorSelectStrategyResponseTime:
+		selected = responseTimeLoadBalance.DoSelect(weightItems)
+	case models.ExecutorSelectStrategyConsistentHash:
+		lb, exist := getConsistentHashLoadBalance(ctx.job.Id)
 		if exist {
-			selected = lb.DoSelect(weightItems)
+			hashKey := ctx.job.GetHashKey()
+			ctx.detail(fmt.Sprintf("一致性哈希键：%s", hashKey))
+			selected = lb.DoSelectByKey(weightItems, hashKey)
 		}
 	}
 
@@ -308,27 +661,29 @@ func (this *HttpTask) shardingTakeover(ctx *scheduleContext, executeNodes []*exe
 
 	var succeeds int
 	for i := 0; i < failedNodes.Size(); i++ {
+		if ctx.stopped() {
+			return false
+		}
 		index := i % len(remains)
 		selected := remains[index]
 		failedNode := failedNodes.Get(i).(*executeNode)
-		executeUrl := this.buildRequestUrl(ctx, &executeNode{
+		succeed := this.executeShard(ctx, &executeNode{
 			address:   selected.address,
 			parameter: failedNode.parameter, //错误节点的分片数据
+			shard:     failedNode.shard,
 		})
-		succeed := this.doExecute(ctx, executeUrl)
 		if succeed {
 			succeeds = succeeds + 1
 			logs.Infof("Job(%s)失败转移,失败节点:%s,转移节点:%s", ctx.job.Name, failedNode.address, selected.address)
 			ctx.detail(fmt.Sprintf("失败转移,失败节点:%s,转移节点:%s", failedNode.address, selected.address))
-			break
 		} else {
 			for _, vvv := range remains {
-				if selected.address != vvv.address {
-					retryUrl := this.buildRequestUrl(ctx, &executeNode{
+				if selected.address != vvv.address && !ctx.stopped() {
+					if this.executeShard(ctx, &executeNode{
 						address:   vvv.address,
 						parameter: failedNode.parameter, //错误节点的分片数据
-					})
-					if this.doExecute(ctx, retryUrl) {
+						shard:     failedNode.shard,
+					}) {
 						logs.Infof("Job(%s)失败转移,失败节点:%s,转移节点:%s", ctx.job.Name, failedNode.address, vvv.address)
 						ctx.detail(fmt.Sprintf("失败转移,失败节点:%s,转移节点:%s", failedNode.address, vvv.address))
 						succeeds = succeeds + 1
@@ -347,14 +702,16 @@ func (this *HttpTask) standaloneTakeover(ctx *scheduleContext, failedNode *execu
 	failedList := make([]*executeNode, 0)
 	failedList = append(failedList, failedNode)
 	for i := 0; i < len(executeNodes)-1; i++ {
+		if ctx.stopped() {
+			return false
+		}
 		remain := remainExecutor(executeNodes, failedList)
 		if remain == nil {
 			return false
 		}
 		logs.Infof("Job(%s) 开始失败转移,失败节点:%s,转移节点:%s", ctx.job.Name, failedNode.address, remain)
 		ctx.detail(fmt.Sprintf("开始故障转移,失败节点:%s,转移节点:%s", failedNode.address, remain.address))
-		executeUrl := this.buildRequestUrl(ctx, remain)
-		takeoverSucceed := this.doExecute(ctx, executeUrl)
+		takeoverSucceed := this.doExecute(ctx, remain)
 		if takeoverSucceed {
 			ctx.detail("转移执行成功")
 			return true
@@ -388,3 +745,33 @@ func remainExecutor(executeNodes []*executeNode, excludes []*executeNode) *execu
 
 	return executeNodes[randomLoadBalance.DoSelect(weightItems)]
 }
+
+// 通知执行节点停止执行
+func notifyExecutorStop(job *models.Job, traceId uint64, addresses []string) {
+	client := httputil.NewHttpClient().SetTimeout(job.Timeout)
+	for _, address := range addresses {
+		stopUrl := job.Protocol + "://" + address
+		if strings.HasPrefix(job.StopUri, "/") {
+			stopUrl = stopUrl + job.StopUri
+		} else {
+			stopUrl = stopUrl + "/" + job.StopUri
+		}
+		executionId := stringutil.UintToStr(traceId)
+		request := client.NewRequest().
+			AddParameter("executionId", executionId).
+			AddHeader("X-Execution-Id", executionId)
+		if models.HttpSignEnabled == job.HttpSign {
+			requestUrl, _ := url.Parse(stringutil.BuildQueryString(stopUrl, map[string]string{"executionId": executionId}))
+			timestamp := strconv.FormatInt(dateutil.NowMillisecond(), 10)
+			request.AddHeader("X-Timestamp", timestamp)
+			request.AddHeader("X-Sign", Signature(requestUrl.RequestURI()+timestamp))
+		}
+		res, err := request.Get(stopUrl)
+		if nil != err {
+			logs.Warnf("Job(%s)通知执行节点%s停止执行错误：%s", job.Name, address, err.Error())
+			continue
+		}
+		res.Body.Close()
+		logs.Infof("Job(%s)通知执行节点%s停止执行，StatusCode：%v", job.Name, address, res.StatusCode)
+	}
+}
@@ -0,0 +1,426 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package icron
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"gojob/util/logs"
+
+	"github.com/robfig/cron"
+)
+
+const (
+	// 默认工作协程数量
+	DefaultWorkers = 200
+	// 待执行队列长度为工作协程数量的倍数
+	pendingQueueFactor = 4
+	// 同时运行的分发执行数量上限为工作协程数量的倍数
+	runningFactor = 50
+	// 没有调度器时定时器的等待时长
+	idleWaitDuration = time.Hour
+)
+
+var (
+	defaultEngine     *Engine
+	defaultEngineOnce sync.Once
+	defaultWorkers    = DefaultWorkers
+)
+
+// 调度引擎
+// 所有调度器共用一个按下次触发时间排序的最小堆和一个定时器，
+// 到期的任务交给固定数量的工作协程执行；工作协程全部繁忙且待执行队列已满时，
+// 引擎等待空闲的工作协程，不会为每次触发创建新的协程。
+// 实现了DispatchJob的任务，工作协程只负责分发，耗时的执行在单独的执行协程中运行，
+// 执行协程数量达到上限时工作协程等待
+type Engine struct {
+	lock     sync.Mutex
+	entries  entryHeap
+	wakeup   chan struct{}
+	done     chan struct{}
+	pending  chan *firing
+	running  chan struct{}
+	workers  int
+	observer func(scheduled time.Time, fired time.Time)
+}
+
+// 分发执行的任务
+// 工作协程调用Dispatch完成触发时的快速处理，Dispatch返回的耗时执行(如HTTP请求、重试和故障转移)
+// 在执行协程中运行，慢任务不会占满工作协程而推迟其它任务的触发；返回nil表示无需继续执行
+type DispatchJob interface {
+	cron.Job
+	Dispatch() func()
+}
+
+// 调度条目
+type entry struct {
+	scheduler *Scheduler
+	next      time.Time
+	index     int
+}
+
+// 一次触发
+type firing struct {
+	scheduler *Scheduler
+	scheduled time.Time
+}
+
+// 按下次触发时间排序的最小堆
+type entryHeap []*entry
+
+func (this entryHeap) Len() int { return len(this) }
+
+func (this entryHeap) Less(i, j int) bool { return this[i].next.Before(this[j].next) }
+
+func (this entryHeap) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+	this[i].index = i
+	this[j].index = j
+}
+
+func (this *entryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*this)
+	*this = append(*this, e)
+}
+
+func (this *entryHeap) Pop() interface{} {
+	old := *this
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*this = old[:n-1]
+	return e
+}
+
+// 初始化默认调度引擎，须在创建调度器之前调用
+func InitEngine(workers int) {
+	if workers > 0 {
+		defaultWorkers = workers
+	}
+	getDefaultEngine()
+}
+
+func getDefaultEngine() *Engine {
+	defaultEngineOnce.Do(func() {
+		defaultEngine = NewEngine(defaultWorkers)
+	})
+	return defaultEngine
+}
+
+// 创建并启动调度引擎
+func NewEngine(workers int) *Engine {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	engine := &Engine{
+		entries: make(entryHeap, 0),
+		wakeup:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		pending: make(chan *firing, workers*pendingQueueFactor),
+		running: make(chan struct{}, workers*runningFactor),
+		workers: workers,
+	}
+	for i := 0; i < workers; i++ {
+		go engine.work()
+	}
+	go engine.run()
+	return engine
+}
+
+// 创建使用此引擎的调度器
+func (this *Engine) NewScheduler(spec string, timezone string, job cron.Job) (*Scheduler, error) {
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	scheduler := this.Schedule(schedule, job)
+	scheduler.spec = spec
+	return scheduler, nil
+}
+
+// 按指定的调度计划创建使用此引擎的调度器
+func (this *Engine) Schedule(schedule cron.Schedule, job cron.Job) *Scheduler {
+	return &Scheduler{
+		job:      job,
+		schedule: schedule,
+		engine:   this,
+	}
+}
+
+// 设置触发观察者，每次任务开始执行时以计划触发时间和实际开始时间回调
+func (this *Engine) SetObserver(observer func(scheduled time.Time, fired time.Time)) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.observer = observer
+}
+
+// 已启动的调度器数量
+func (this *Engine) Size() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return len(this.entries)
+}
+
+// 等待工作协程执行的触发数量
+func (this *Engine) Pending() int {
+	return len(this.pending)
+}
+
+// 运行中的分发执行数量
+func (this *Engine) Running() int {
+	return len(this.running)
+}
+
+// 工作协程数量
+func (this *Engine) Workers() int {
+	return this.workers
+}
+
+// 关闭调度引擎
+func (this *Engine) Close() {
+	close(this.done)
+}
+
+func (this *Engine) add(scheduler *Scheduler) {
+	this.lock.Lock()
+	if nil == scheduler.entry {
+		next := scheduler.schedule.Next(time.Now())
+		if !next.IsZero() {
+			scheduler.entry = &entry{
+				scheduler: scheduler,
+				next:      next,
+			}
+			heap.Push(&this.entries, scheduler.entry)
+		}
+	}
+	this.lock.Unlock()
+	this.notify()
+}
+
+func (this *Engine) remove(scheduler *Scheduler) {
+	this.lock.Lock()
+	if nil != scheduler.entry {
+		if scheduler.entry.index >= 0 {
+			heap.Remove(&this.entries, scheduler.entry.index)
+		}
+		scheduler.entry = nil
+	}
+	this.lock.Unlock()
+	this.notify()
+}
+
+func (this *Engine) nextTime(scheduler *Scheduler) time.Time {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if nil == scheduler.entry {
+		return time.Time{}
+	}
+	return scheduler.entry.next
+}
+
+func (this *Engine) unfinishedTime(scheduler *Scheduler) time.Time {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var earliest time.Time
+	if nil != scheduler.entry {
+		earliest = scheduler.entry.next
+	}
+	for _, scheduled := range scheduler.firings {
+		if earliest.IsZero() || scheduled.Before(earliest) {
+			earliest = scheduled
+		}
+	}
+	return earliest
+}
+
+// 触发执行完毕，从调度器的未完成触发中移除
+func (this *Engine) finish(f *firing) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	firings := f.scheduler.firings
+	for i, scheduled := range firings {
+		if scheduled.Equal(f.scheduled) {
+			f.scheduler.firings = append(firings[:i], firings[i+1:]...)
+			return
+		}
+	}
+}
+
+func (this *Engine) notify() {
+	select {
+	case this.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// 距离最近一次触发的等待时长
+func (this *Engine) waitDuration() time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if len(this.entries) == 0 {
+		return idleWaitDuration
+	}
+	return time.Until(this.entries[0].next)
+}
+
+func (this *Engine) run() {
+	timer := time.NewTimer(this.waitDuration())
+	for {
+		select {
+		case now := <-timer.C:
+			this.fire(now)
+		case <-this.wakeup:
+		case <-this.done:
+			timer.Stop()
+			close(this.pending)
+			return
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(this.waitDuration())
+	}
+}
+
+// 逐个取出到期的条目交给工作协程，并计算它们的下次触发时间
+func (this *Engine) fire(now time.Time) {
+	for {
+		f := this.popDue(now)
+		if nil == f {
+			return
+		}
+		select {
+		case this.pending <- f:
+		case <-this.done:
+			return
+		}
+	}
+}
+
+func (this *Engine) popDue(now time.Time) *firing {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if len(this.entries) == 0 || this.entries[0].next.After(now) {
+		return nil
+	}
+	e := this.entries[0]
+	f := &firing{
+		scheduler: e.scheduler,
+		scheduled: e.next,
+	}
+	e.scheduler.firings = append(e.scheduler.firings, e.next)
+	if completesAfterRun(e.scheduler.schedule) {
+		// 执行完毕后由工作协程重新放回
+		heap.Pop(&this.entries)
+		e.next = time.Time{}
+		return f
+	}
+	e.next = e.scheduler.schedule.Next(now)
+	if e.next.IsZero() {
+		heap.Pop(&this.entries)
+		e.scheduler.entry = nil
+	} else {
+		heap.Fix(&this.entries, 0)
+	}
+	return f
+}
+
+func (this *Engine) work() {
+	for f := range this.pending {
+		this.execute(f)
+	}
+}
+
+func (this *Engine) execute(f *firing) {
+	this.lock.Lock()
+	observer := this.observer
+	this.lock.Unlock()
+	if nil != observer {
+		observer(f.scheduled, time.Now())
+	}
+
+	job, dispatchable := f.scheduler.job.(DispatchJob)
+	if !dispatchable {
+		this.runFiring(f, f.scheduler.job.Run)
+		return
+	}
+	var run func()
+	protect(func() {
+		run = job.Dispatch()
+	})
+	if nil == run {
+		this.complete(f)
+		return
+	}
+	this.running <- struct{}{}
+	go func() {
+		defer func() { <-this.running }()
+		this.runFiring(f, run)
+	}()
+}
+
+func (this *Engine) runFiring(f *firing, run func()) {
+	defer this.complete(f)
+	protect(run)
+}
+
+// 触发执行完毕，固定延迟的调度按执行完毕的时间重新计算下次触发时间
+func (this *Engine) complete(f *firing) {
+	if completesAfterRun(f.scheduler.schedule) {
+		this.reschedule(f.scheduler)
+	}
+	this.finish(f)
+}
+
+func protect(run func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logs.Errorf("任务执行异常：%v", r)
+		}
+	}()
+	run()
+}
+
+// 按执行完毕的时间重新计算下次触发时间；期间调度器已停止或重新启动时不做处理
+func (this *Engine) reschedule(scheduler *Scheduler) {
+	this.lock.Lock()
+	if nil != scheduler.entry && scheduler.entry.index < 0 {
+		scheduler.entry.next = scheduler.schedule.Next(time.Now())
+		if scheduler.entry.next.IsZero() {
+			scheduler.entry = nil
+		} else {
+			heap.Push(&this.entries, scheduler.entry)
+		}
+	}
+	this.lock.Unlock()
+	this.notify()
+}
@@ -0,0 +1,212 @@
+package icron
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+	"go.uber.org/atomic"
+)
+
+func TestEngine(t *testing.T) {
+	engine := NewEngine(4)
+	defer engine.Close()
+
+	var fired atomic.Int32
+	scheduler, err := engine.NewScheduler("* * * * * *", "", cron.FuncJob(func() {
+		fired.Inc()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	if engine.Size() != 1 {
+		t.Fatalf("expected 1 entry, actual %d", engine.Size())
+	}
+	if next := scheduler.GetNextTime(); next <= time.Now().Unix()-1 || next > time.Now().Unix()+1 {
+		t.Errorf("unexpected next time %d", next)
+	}
+
+	time.Sleep(2500 * time.Millisecond)
+	scheduler.Stop()
+	if engine.Size() != 0 {
+		t.Fatalf("expected 0 entry, actual %d", engine.Size())
+	}
+	count := fired.Load()
+	if count < 2 || count > 3 {
+		t.Errorf("expected 2 or 3 firings, actual %d", count)
+	}
+
+	time.Sleep(1200 * time.Millisecond)
+	if fired.Load() != count {
+		t.Errorf("stopped scheduler fired")
+	}
+}
+
+func TestEngineUnfinished(t *testing.T) {
+	engine := NewEngine(1)
+	defer engine.Close()
+
+	release := make(chan struct{})
+	var fired atomic.Int32
+	scheduler, err := engine.NewScheduler("* * * * * *", "", cron.FuncJob(func() {
+		if fired.Inc() == 1 {
+			<-release
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Start()
+	first := time.Now().Unix() + 1
+
+	// 第一次触发阻塞在工作协程中，后续触发在待执行队列中等待
+	time.Sleep(2500 * time.Millisecond)
+	if unfinished := scheduler.GetUnfinishedTime(); unfinished != first {
+		t.Errorf("expected unfinished time %d, actual %d", first, unfinished)
+	}
+
+	scheduler.Stop()
+	close(release)
+	time.Sleep(200 * time.Millisecond)
+	if unfinished := scheduler.GetUnfinishedTime(); unfinished != 0 {
+		t.Errorf("expected no unfinished firing, actual %d", unfinished)
+	}
+}
+
+type dispatchFunc func() func()
+
+func (this dispatchFunc) Run() {
+	if run := this(); nil != run {
+		run()
+	}
+}
+
+func (this dispatchFunc) Dispatch() func() {
+	return this()
+}
+
+func TestEngineSlowDispatch(t *testing.T) {
+	engine := NewEngine(1)
+	defer engine.Close()
+
+	release := make(chan struct{})
+	schedulers := make([]*Scheduler, 0)
+	// 执行耗时很长的任务占用执行协程，不占用唯一的工作协程
+	for i := 0; i < 3; i++ {
+		scheduler, err := engine.NewScheduler("* * * * * *", "", dispatchFunc(func() func() {
+			return func() {
+				<-release
+			}
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedulers = append(schedulers, scheduler)
+	}
+	var fired atomic.Int32
+	fast, err := engine.NewScheduler("* * * * * *", "", cron.FuncJob(func() {
+		fired.Inc()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedulers = append(schedulers, fast)
+	for _, scheduler := range schedulers {
+		scheduler.Start()
+	}
+
+	time.Sleep(2500 * time.Millisecond)
+	for _, scheduler := range schedulers {
+		scheduler.Stop()
+	}
+	if count := fired.Load(); count < 2 {
+		t.Errorf("expected the fast job to fire at least twice, actual %d", count)
+	}
+	if running := engine.Running(); running < 6 {
+		t.Errorf("expected at least 6 running dispatches, actual %d", running)
+	}
+
+	close(release)
+	time.Sleep(200 * time.Millisecond)
+	if running := engine.Running(); running != 0 {
+		t.Errorf("expected no running dispatch, actual %d", running)
+	}
+}
+
+// 50000个每秒触发一次的任务共用一个调度引擎，
+// 输出调度器占用的内存、协程数量以及从计划触发时间到开始执行的延迟分布
+func BenchmarkEngine50kJobs(b *testing.B) {
+	const jobs = 50000
+
+	engine := NewEngine(DefaultWorkers)
+	defer engine.Close()
+
+	var lock sync.Mutex
+	latencies := make([]time.Duration, 0, jobs*4)
+	engine.SetObserver(func(scheduled time.Time, fired time.Time) {
+		lock.Lock()
+		latencies = append(latencies, fired.Sub(scheduled))
+		lock.Unlock()
+	})
+
+	var fired atomic.Int64
+	job := cron.FuncJob(func() {
+		fired.Inc()
+	})
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	schedulers := make([]*Scheduler, 0, jobs)
+	for i := 0; i < jobs; i++ {
+		scheduler, err := engine.NewScheduler("* * * * * *", "Asia/Shanghai", job)
+		if err != nil {
+			b.Fatal(err)
+		}
+		scheduler.Start()
+		schedulers = append(schedulers, scheduler)
+	}
+	runtime.GC()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	// 等待第一个完整的触发周期开始
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	lock.Lock()
+	latencies = latencies[:0]
+	lock.Unlock()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target := fired.Load() + jobs
+		for fired.Load() < target {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	b.StopTimer()
+
+	for _, scheduler := range schedulers {
+		scheduler.Stop()
+	}
+
+	lock.Lock()
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	lock.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[int(float64(len(sorted)-1)*p)]
+	}
+
+	b.Logf("jobs: %d, workers: %d, goroutines: %d", jobs, engine.Workers(), runtime.NumGoroutine())
+	b.Logf("scheduler memory: %d KB total, %d B/job", (after.HeapAlloc-before.HeapAlloc)/1024, (after.HeapAlloc-before.HeapAlloc)/jobs)
+	b.Logf("firing latency over %d firings: p50 %v, p90 %v, p99 %v, max %v",
+		len(sorted), percentile(0.5), percentile(0.9), percentile(0.99), percentile(1))
+}
@@ -15,15 +15,28 @@
  * limitations under the License.
  * </p>
  */
+// Cron表达式由6个字段组成，依次为：秒 分 时 日 月 周，其中"周"可以省略；
+// 也支持@yearly、@monthly、@weekly、@daily、@hourly、@every 1h30m等描述符。
+// 作业可以指定时区(IANA名称，如Asia/Tokyo)，未指定时使用调度节点所在的时区。
+// 表达式按照时区的当地时间计算触发时间点：
+// 夏令时开始时，落在被跳过时段内的触发时间点顺延相同的时长(如02:30顺延至03:30)；
+// 夏令时结束时，重复出现的当地时间只触发一次(第一次出现时触发)。
 package icron
 
 import (
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
 	"go.uber.org/atomic"
 )
 
+// 计算下次执行时间的最大尝试次数，避免夏令时结束时重复的当地时间导致死循环
+const maxNextAttempts = 16
+
+// 已加载的时区，同一时区的调度器共用一个*time.Location
+var locations sync.Map
+
 // 调度任务
 type TaskFunc func()
 
@@ -32,9 +45,12 @@ type Scheduler struct {
 	started   atomic.Bool // 0停止  1运行
 	startTime int64
 	spec      string
-	cronLab   *cron.Cron
-	taskFunc  TaskFunc // 任务
-	job       cron.Job // 任务
+	schedule  cron.Schedule
+	engine    *Engine
+	entry     *entry      // 在调度引擎中的条目，由引擎的锁保护
+	firings   []time.Time // 已到期但尚未执行完毕的触发时间点，由引擎的锁保护
+	taskFunc  TaskFunc    // 任务
+	job       cron.Job    // 任务
 }
 
 // 启动Cron调度器
@@ -44,28 +60,37 @@ func (this *Scheduler) Start() {
 	}
 	this.started.Store(true)
 	this.startTime = time.Now().Unix()
-	this.cronLab.Start()
+	this.engine.add(this)
 }
 
 // 停止Cron调度器
 func (this *Scheduler) Stop() {
 	if this.started.Load() {
-		this.cronLab.Stop()
+		this.engine.remove(this)
 		this.started.Store(false)
 	}
 }
 
-// 获取下次执行时间
+// 获取下次执行时间，不会再触发时返回0
 func (this *Scheduler) GetNextTime() int64 {
 	if this.started.Load() {
-		entries := this.cronLab.Entries()
-		if nil != entries && nil != entries[0] {
-			return entries[0].Next.Unix()
+		if next := this.engine.nextTime(this); !next.IsZero() {
+			return next.Unix()
 		}
 	}
-	now := time.Now().In(this.cronLab.Location())
-	actual, _ := cron.Parse(this.spec)
-	return actual.Next(now).Unix()
+	if next := this.schedule.Next(time.Now()); !next.IsZero() {
+		return next.Unix()
+	}
+	return 0
+}
+
+// 获取调度引擎仍会执行的最早触发时间点：等待工作协程或正在执行的触发，以及下次触发时间；
+// 不早于此时间点的触发不应当作错发补偿。不存在时返回0
+func (this *Scheduler) GetUnfinishedTime() int64 {
+	if earliest := this.engine.unfinishedTime(this); !earliest.IsZero() {
+		return earliest.Unix()
+	}
+	return 0
 }
 
 // 获取Task
@@ -79,33 +104,58 @@ func (this *Scheduler) GetJob() cron.Job {
 }
 
 // 创建Cron调度器
-func NewFuncScheduler(spec string, task TaskFunc) (*Scheduler, error) {
-	scheduler := &Scheduler{
-		taskFunc: task,
-		spec:     spec,
-	}
-	cronLab := cron.New()
-	err := cronLab.AddFunc(spec, task)
+func NewFuncScheduler(spec string, timezone string, task TaskFunc) (*Scheduler, error) {
+	scheduler, err := NewJobScheduler(spec, timezone, cron.FuncJob(task))
 	if err != nil {
 		return nil, err
 	}
-	scheduler.cronLab = cronLab
+	scheduler.taskFunc = task
 	return scheduler, nil
 }
 
-// 创建Cron调度器
-func NewJobScheduler(spec string, job cron.Job) (*Scheduler, error) {
-	scheduler := &Scheduler{
-		job:  job,
-		spec: spec,
+// 创建Cron调度器，使用默认调度引擎
+func NewJobScheduler(spec string, timezone string, job cron.Job) (*Scheduler, error) {
+	return getDefaultEngine().NewScheduler(spec, timezone, job)
+}
+
+// 按指定的调度计划创建调度器，使用默认调度引擎
+func ScheduleJob(schedule cron.Schedule, job cron.Job) *Scheduler {
+	return getDefaultEngine().Schedule(schedule, job)
+}
+
+// 加载时区，为空时使用调度节点所在的时区
+func LoadLocation(timezone string) (*time.Location, error) {
+	if "" == timezone {
+		return time.Local, nil
+	}
+	if location, ok := locations.Load(timezone); ok {
+		return location.(*time.Location), nil
 	}
-	cronLab := cron.New()
-	err := cronLab.AddJob(spec, job)
+	location, err := time.LoadLocation(timezone)
 	if err != nil {
 		return nil, err
 	}
-	scheduler.cronLab = cronLab
-	return scheduler, nil
+	locations.Store(timezone, location)
+	return location, nil
+}
+
+// 解析Cron表达式，得到按时区计算触发时间点的调度计划
+func ParseSpec(spec string, timezone string) (cron.Schedule, error) {
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if specSchedule, ok := schedule.(*cron.SpecSchedule); ok {
+		return &zonedSchedule{
+			spec:     specSchedule,
+			location: location,
+		}, nil
+	}
+	return schedule, nil
 }
 
 // 验证Cron表达式
@@ -114,11 +164,168 @@ func ValidateCronSpec(spec string) error {
 	return err
 }
 
+// 验证时区
+func ValidateTimezone(timezone string) error {
+	_, err := LoadLocation(timezone)
+	return err
+}
+
+// 获取指定时间之后的下次执行时间，无法计算时返回零值
+func NextTime(spec string, timezone string, from time.Time) time.Time {
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(from)
+}
+
+// 获取(after, until]区间内应当触发的时间点，最多返回limit个
+func MissedTimes(spec string, timezone string, after time.Time, until time.Time, limit int) ([]time.Time, error) {
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	return ScheduleMissedTimes(schedule, after, until, limit), nil
+}
+
 // 获取两次执行时间的间隔
-func GetTimeStep(spec string) int64 {
-	now := time.Now()
-	actual, _ := cron.Parse(spec)
-	next1 := actual.Next(now)
-	next2 := actual.Next(next1)
+func GetTimeStep(spec string, timezone string) int64 {
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		return 0
+	}
+	next1 := schedule.Next(time.Now())
+	next2 := schedule.Next(next1)
 	return next2.Unix() - next1.Unix()
 }
+
+// 触发时间预览
+type Preview struct {
+	FireTimes []time.Time // 触发时间点
+	MinStep   int64       // 相邻两次触发的最小间隔（秒）
+	MaxStep   int64       // 相邻两次触发的最大间隔（秒）
+}
+
+// 触发间隔是否固定
+func (this *Preview) IsConstantStep() bool {
+	return this.MinStep == this.MaxStep
+}
+
+// 预览指定时间之后的count个触发时间点
+func PreviewSpec(spec string, timezone string, from time.Time, count int) (*Preview, error) {
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	return PreviewSchedule(schedule, location, from, count), nil
+}
+
+// 预览调度计划在指定时间之后的count个触发时间点，触发时间点按location显示
+func PreviewSchedule(schedule cron.Schedule, location *time.Location, from time.Time, count int) *Preview {
+	preview := &Preview{
+		FireTimes: make([]time.Time, 0, count),
+	}
+	next := from
+	for i := 0; i < count; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		if len(preview.FireTimes) > 0 {
+			step := next.Unix() - preview.FireTimes[len(preview.FireTimes)-1].Unix()
+			if 0 == preview.MinStep || step < preview.MinStep {
+				preview.MinStep = step
+			}
+			if step > preview.MaxStep {
+				preview.MaxStep = step
+			}
+		}
+		preview.FireTimes = append(preview.FireTimes, next.In(location))
+	}
+	return preview
+}
+
+// 按时区当地时间计算触发时间点的调度计划
+type zonedSchedule struct {
+	spec     *cron.SpecSchedule
+	location *time.Location
+}
+
+// 在UTC中用当地时间(不受夏令时影响)计算下一个触发时间点，再换算回时区
+func (this *zonedSchedule) Next(t time.Time) time.Time {
+	wall := toWall(t.In(this.location))
+	for i := 0; i < maxNextAttempts; i++ {
+		wall = this.spec.Next(wall)
+		if wall.IsZero() {
+			return wall
+		}
+		next := fromWall(wall, this.location)
+		if next.After(t) {
+			return next
+		}
+		// 夏令时结束时当地时间重复，t处于第二次出现的时段中；
+		// 重复时段内的当地时间已在第一次出现时触发过，直接跳到重复时段结束
+		if end, ok := repeatedWallEnd(next, t, this.location); ok && end.After(wall) {
+			wall = end.Add(-time.Second)
+		}
+	}
+	return time.Time{}
+}
+
+// 计算重复时段结束时的当地时间(以UTC表示)；first为重复时段中第一次出现的时间，t为第二次出现的时段中的时间
+func repeatedWallEnd(first time.Time, t time.Time, location *time.Location) (time.Time, bool) {
+	_, oldOffset := first.In(location).Zone()
+	_, newOffset := t.In(location).Zone()
+	if oldOffset <= newOffset {
+		return time.Time{}, false
+	}
+	// 二分查找偏移量切换的时刻
+	lo, hi := first, t
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if _, offset := mid.In(location).Zone(); offset == oldOffset {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	switched := hi.Truncate(time.Second)
+	if _, offset := switched.In(location).Zone(); offset == oldOffset {
+		switched = switched.Add(time.Second)
+	}
+	// 切换时刻在切换前偏移量下的当地时间即重复时段的结束
+	return time.Unix(switched.Unix()+int64(oldOffset), 0).UTC(), true
+}
+
+// 将时间的当地时间表示为UTC时间
+func toWall(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// 将当地时间换算为时区中的时间：
+// 存在多个对应时间时取最早的一个，不存在时(夏令时跳过的时段)按切换前的偏移量顺延
+func fromWall(wall time.Time, location *time.Location) time.Time {
+	var earliest time.Time
+	offsets := make([]int, 0, 3)
+	for _, probe := range []time.Duration{-36 * time.Hour, 0, 36 * time.Hour} {
+		_, offset := wall.Add(probe).In(location).Zone()
+		offsets = append(offsets, offset)
+	}
+	for _, offset := range offsets {
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(location)
+		if !toWall(candidate).Equal(wall) {
+			continue
+		}
+		if earliest.IsZero() || candidate.Before(earliest) {
+			earliest = candidate
+		}
+	}
+	if earliest.IsZero() {
+		earliest = wall.Add(-time.Duration(offsets[0]) * time.Second).In(location)
+	}
+	return earliest
+}
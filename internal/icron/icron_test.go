@@ -0,0 +1,222 @@
+package icron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("时区数据不可用：%s", err.Error())
+	}
+	return location
+}
+
+func fireTimes(t *testing.T, spec string, timezone string, from time.Time, count int) []time.Time {
+	schedule, err := ParseSpec(spec, timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := make([]time.Time, 0, count)
+	next := from
+	for i := 0; i < count; i++ {
+		next = schedule.Next(next)
+		list = append(list, next)
+	}
+	return list
+}
+
+func TestTimezone(t *testing.T) {
+	tokyo := mustLocation(t, "Asia/Tokyo")
+	berlin := mustLocation(t, "Europe/Berlin")
+	from := time.Date(2026, 1, 9, 20, 0, 0, 0, time.UTC)
+
+	next := fireTimes(t, "0 0 9 * * *", "Asia/Tokyo", from, 1)[0]
+	if expected := time.Date(2026, 1, 10, 9, 0, 0, 0, tokyo); !next.Equal(expected) {
+		t.Errorf("expected %v, actual %v", expected, next)
+	}
+	next = fireTimes(t, "0 0 9 * * *", "Europe/Berlin", from, 1)[0]
+	if expected := time.Date(2026, 1, 10, 9, 0, 0, 0, berlin); !next.Equal(expected) {
+		t.Errorf("expected %v, actual %v", expected, next)
+	}
+}
+
+// 夏令时开始：2026-03-29 02:00 CET 跳至 03:00 CEST
+func TestSpringForward(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	from := time.Date(2026, 3, 28, 12, 0, 0, 0, berlin)
+
+	times := fireTimes(t, "0 30 2 * * *", "Europe/Berlin", from, 3)
+	expected := []time.Time{
+		time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), // 当地不存在02:30，顺延至03:30 CEST
+		time.Date(2026, 3, 30, 2, 30, 0, 0, berlin),
+		time.Date(2026, 3, 31, 2, 30, 0, 0, berlin),
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("%d: expected %v, actual %v", i, expected[i].In(berlin), times[i].In(berlin))
+		}
+	}
+
+	// 每日09:00不受影响，两次触发间隔23小时
+	times = fireTimes(t, "0 0 9 * * *", "Europe/Berlin", time.Date(2026, 3, 28, 0, 0, 0, 0, berlin), 2)
+	if diff := times[1].Sub(times[0]); diff != 23*time.Hour {
+		t.Errorf("expected 23h, actual %v", diff)
+	}
+
+	// 每半小时：跳过的时段不重复触发
+	times = fireTimes(t, "0 0,30 * * * *", "Europe/Berlin", time.Date(2026, 3, 29, 1, 0, 0, 0, berlin), 4)
+	expected = []time.Time{
+		time.Date(2026, 3, 29, 1, 30, 0, 0, berlin),
+		time.Date(2026, 3, 29, 3, 0, 0, 0, berlin),
+		time.Date(2026, 3, 29, 3, 30, 0, 0, berlin),
+		time.Date(2026, 3, 29, 4, 0, 0, 0, berlin),
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("%d: expected %v, actual %v", i, expected[i].In(berlin), times[i].In(berlin))
+		}
+	}
+}
+
+// 夏令时结束：2026-10-25 03:00 CEST 回拨至 02:00 CET
+func TestFallBack(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	from := time.Date(2026, 10, 24, 12, 0, 0, 0, berlin)
+
+	times := fireTimes(t, "0 30 2 * * *", "Europe/Berlin", from, 2)
+	expected := []time.Time{
+		time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), // 第一次出现的02:30 CEST
+		time.Date(2026, 10, 26, 2, 30, 0, 0, berlin),
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("%d: expected %v, actual %v", i, expected[i].In(berlin), times[i].In(berlin))
+		}
+	}
+
+	// 第二次出现的02:10 CET之后，当天的02:30已经触发过，不再触发
+	secondPass := time.Date(2026, 10, 25, 1, 10, 0, 0, time.UTC)
+	next := fireTimes(t, "0 30 2 * * *", "Europe/Berlin", secondPass, 1)[0]
+	if expected := time.Date(2026, 10, 26, 2, 30, 0, 0, berlin); !next.Equal(expected) {
+		t.Errorf("expected %v, actual %v", expected, next.In(berlin))
+	}
+
+	// 每日09:00不受影响，两次触发间隔25小时
+	times = fireTimes(t, "0 0 9 * * *", "Europe/Berlin", time.Date(2026, 10, 24, 0, 0, 0, 0, berlin), 2)
+	if diff := times[1].Sub(times[0]); diff != 25*time.Hour {
+		t.Errorf("expected 25h, actual %v", diff)
+	}
+}
+
+// 每分钟、每秒触发的作业在第二次出现的时段中不会停止触发，重复时段结束后继续
+func TestFallBackFrequent(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	secondPass := time.Date(2026, 10, 25, 1, 10, 0, 0, time.UTC)
+	cases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"0 * * * * *", time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC)},    // 03:00 CET
+		{"* * * * * *", time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC)},    // 03:00:00 CET
+		{"0 */15 * * * *", time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC)}, // 03:00 CET
+		{"30 5 * * * *", time.Date(2026, 10, 25, 2, 5, 30, 0, time.UTC)},  // 03:05:30 CET
+	}
+	for _, c := range cases {
+		times := fireTimes(t, c.spec, "Europe/Berlin", secondPass, 2)
+		if !times[0].Equal(c.expected) {
+			t.Errorf("%s: expected %v, actual %v", c.spec, c.expected.In(berlin), times[0].In(berlin))
+		}
+		if !times[1].After(times[0]) {
+			t.Errorf("%s: expected firing after %v, actual %v", c.spec, times[0].In(berlin), times[1])
+		}
+	}
+
+	// 第一次出现的时段中正常按分钟触发，连续跨过切换时刻
+	times := fireTimes(t, "0 * * * * *", "Europe/Berlin", time.Date(2026, 10, 25, 0, 58, 30, 0, time.UTC), 3)
+	expected := []time.Time{
+		time.Date(2026, 10, 25, 0, 59, 0, 0, time.UTC), // 02:59 CEST
+		time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC),  // 03:00 CET，重复的02:xx CET不再触发
+		time.Date(2026, 10, 25, 2, 1, 0, 0, time.UTC),
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("%d: expected %v, actual %v", i, expected[i].In(berlin), times[i].In(berlin))
+		}
+	}
+}
+
+func TestFallBackNewYork(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	from := time.Date(2026, 10, 31, 12, 0, 0, 0, newYork)
+	times := fireTimes(t, "0 30 1 * * *", "America/New_York", from, 2)
+	expected := []time.Time{
+		time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), // 第一次出现的01:30 EDT
+		time.Date(2026, 11, 2, 1, 30, 0, 0, newYork),
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("%d: expected %v, actual %v", i, expected[i].In(newYork), times[i].In(newYork))
+		}
+	}
+}
+
+func TestSpringForwardNewYork(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	from := time.Date(2026, 3, 7, 12, 0, 0, 0, newYork)
+	next := fireTimes(t, "0 30 2 * * *", "America/New_York", from, 1)[0]
+	if expected := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected %v, actual %v", expected.In(newYork), next.In(newYork))
+	}
+}
+
+func TestPreviewSpec(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	preview, err := PreviewSpec("0 0 0 1,15 * *", "UTC", from, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.FireTimes) != 4 {
+		t.Fatalf("expected 4 fire times, actual %d", len(preview.FireTimes))
+	}
+	if preview.IsConstantStep() {
+		t.Error("expected irregular step")
+	}
+	if preview.MinStep != 14*86400 || preview.MaxStep != 17*86400 {
+		t.Errorf("unexpected step %d - %d", preview.MinStep, preview.MaxStep)
+	}
+
+	preview, _ = PreviewSpec("0 */5 * * * *", "UTC", from, 10)
+	if !preview.IsConstantStep() || preview.MinStep != 300 {
+		t.Errorf("expected constant step 300, actual %d - %d", preview.MinStep, preview.MaxStep)
+	}
+}
+
+func TestMissedTimes(t *testing.T) {
+	after := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC)
+	missed, err := MissedTimes("0 0 2 1,15 * *", "UTC", after, until, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2026-02-01", "2026-02-15", "2026-03-01", "2026-03-15", "2026-04-01", "2026-04-15"}
+	if len(missed) != len(expected) {
+		t.Fatalf("expected %d missed times, actual %d", len(expected), len(missed))
+	}
+	for i, v := range missed {
+		if v.Format("2006-01-02") != expected[i] {
+			t.Errorf("expected %s, actual %s", expected[i], v.Format("2006-01-02"))
+		}
+	}
+
+	missed, _ = MissedTimes("0 0 2 1,15 * *", "UTC", after, until, 2)
+	if len(missed) != 2 {
+		t.Errorf("expected 2 missed times, actual %d", len(missed))
+	}
+
+	missed, _ = MissedTimes("0 0 2 * * MON-FRI", "UTC", time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC), time.Date(2026, 1, 5, 1, 0, 0, 0, time.UTC), 100)
+	if len(missed) != 0 {
+		t.Errorf("expected no missed times over the weekend, actual %d", len(missed))
+	}
+}
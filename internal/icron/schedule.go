@@ -0,0 +1,155 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package icron
+
+import (
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// 固定延迟的调度计划在任务执行完毕后才计算下次触发时间
+type completionSchedule interface {
+	cron.Schedule
+	afterCompletion()
+}
+
+// 限定有效期的调度计划，只在[from, until]内触发
+type boundedSchedule struct {
+	schedule cron.Schedule
+	from     time.Time
+	until    time.Time
+}
+
+func (this *boundedSchedule) Next(t time.Time) time.Time {
+	if !this.from.IsZero() && t.Before(this.from) {
+		t = this.from.Add(-time.Second)
+	}
+	next := this.schedule.Next(t)
+	if !this.until.IsZero() && next.After(this.until) {
+		return time.Time{}
+	}
+	return next
+}
+
+// 是否在任务执行完毕后才计算下次触发时间
+func completesAfterRun(schedule cron.Schedule) bool {
+	if bounded, ok := schedule.(*boundedSchedule); ok {
+		schedule = bounded.schedule
+	}
+	_, ok := schedule.(completionSchedule)
+	return ok
+}
+
+// 一次性调度计划，在指定时间点触发一次
+type onceSchedule struct {
+	at time.Time
+}
+
+func (this *onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(this.at) {
+		return this.at
+	}
+	return time.Time{}
+}
+
+// 固定频率调度计划，从起始时间开始每隔interval触发一次，不受任务执行时长影响
+type fixedRateSchedule struct {
+	start    time.Time
+	interval time.Duration
+}
+
+func (this *fixedRateSchedule) Next(t time.Time) time.Time {
+	if t.Before(this.start) {
+		return this.start
+	}
+	periods := t.Sub(this.start)/this.interval + 1
+	return this.start.Add(periods * this.interval)
+}
+
+// 固定延迟调度计划，首次在起始时间触发，之后在上次执行完毕delay后触发(向上取整到秒)
+type fixedDelaySchedule struct {
+	start time.Time
+	delay time.Duration
+}
+
+func (this *fixedDelaySchedule) Next(t time.Time) time.Time {
+	if t.Before(this.start) {
+		return this.start
+	}
+	next := t.Add(this.delay)
+	if truncated := next.Truncate(time.Second); truncated.Before(next) {
+		return truncated.Add(time.Second)
+	}
+	return next
+}
+
+func (this *fixedDelaySchedule) afterCompletion() {}
+
+// 在指定时间点触发一次
+func Once(at time.Time) cron.Schedule {
+	return &onceSchedule{
+		at: at.Truncate(time.Second),
+	}
+}
+
+// 从起始时间开始按固定频率触发，interval不足1秒时按1秒计算
+func FixedRate(start time.Time, interval time.Duration) cron.Schedule {
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return &fixedRateSchedule{
+		start:    start.Truncate(time.Second),
+		interval: interval.Truncate(time.Second),
+	}
+}
+
+// 从起始时间开始，每次执行完毕后间隔固定时长再触发，delay不足1秒时按1秒计算
+func FixedDelay(start time.Time, delay time.Duration) cron.Schedule {
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return &fixedDelaySchedule{
+		start: start.Truncate(time.Second),
+		delay: delay.Truncate(time.Second),
+	}
+}
+
+// 限定调度计划的有效期，from或until为零值时表示不限制
+func Bounded(schedule cron.Schedule, from time.Time, until time.Time) cron.Schedule {
+	if from.IsZero() && until.IsZero() {
+		return schedule
+	}
+	return &boundedSchedule{
+		schedule: schedule,
+		from:     from,
+		until:    until,
+	}
+}
+
+// 获取(after, until]区间内应当触发的时间点，最多返回limit个
+func ScheduleMissedTimes(schedule cron.Schedule, after time.Time, until time.Time, limit int) []time.Time {
+	missed := make([]time.Time, 0)
+	for next := schedule.Next(after); !next.IsZero() && !next.After(until); next = schedule.Next(next) {
+		if len(missed) >= limit {
+			break
+		}
+		missed = append(missed, next)
+	}
+	return missed
+}
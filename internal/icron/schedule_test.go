@@ -0,0 +1,97 @@
+package icron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+func TestOnce(t *testing.T) {
+	at := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC)
+	schedule := Once(at)
+	if next := schedule.Next(at.Add(-time.Hour)); !next.Equal(at) {
+		t.Errorf("expected %v, actual %v", at, next)
+	}
+	if next := schedule.Next(at); !next.IsZero() {
+		t.Errorf("expected zero time after firing, actual %v", next)
+	}
+}
+
+func TestFixedRate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := FixedRate(start, 10*time.Minute)
+	cases := map[time.Time]time.Time{
+		start.Add(-time.Second):                 start,
+		start:                                   start.Add(10 * time.Minute),
+		start.Add(25 * time.Minute):             start.Add(30 * time.Minute),
+		start.Add(30*time.Minute + time.Second): start.Add(40 * time.Minute),
+	}
+	for from, expected := range cases {
+		if next := schedule.Next(from); !next.Equal(expected) {
+			t.Errorf("from %v expected %v, actual %v", from, expected, next)
+		}
+	}
+}
+
+func TestFixedDelay(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := FixedDelay(start, time.Minute)
+	if next := schedule.Next(start.Add(-time.Hour)); !next.Equal(start) {
+		t.Errorf("expected %v, actual %v", start, next)
+	}
+	finished := start.Add(47*time.Second + 300*time.Millisecond)
+	if next := schedule.Next(finished); !next.Equal(start.Add(108 * time.Second)) {
+		t.Errorf("expected %v, actual %v", start.Add(108*time.Second), next)
+	}
+}
+
+func TestEngineFixedDelay(t *testing.T) {
+	engine := NewEngine(2)
+	defer engine.Close()
+
+	runs := make(chan time.Time, 4)
+	scheduler := engine.Schedule(FixedDelay(time.Now().Add(time.Second), time.Second), cron.FuncJob(func() {
+		runs <- time.Now()
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	first := <-runs
+	if scheduler.GetNextTime() < first.Unix()+1 {
+		t.Errorf("next time should not be before the running execution finishes")
+	}
+	second := <-runs
+	if gap := second.Sub(first); gap < 2500*time.Millisecond {
+		t.Errorf("expected at least 2.5s between runs, actual %v", gap)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 11, 3, 12, 0, 0, 0, time.UTC)
+	schedule, err := ParseSpec("0 0 9 * * *", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounded := Bounded(schedule, from, until)
+	preview := PreviewSchedule(bounded, time.UTC, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), 10)
+	if len(preview.FireTimes) != 3 {
+		t.Fatalf("expected 3 fire times, actual %d", len(preview.FireTimes))
+	}
+	if !preview.FireTimes[0].Equal(time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first fire time %v", preview.FireTimes[0])
+	}
+	// 截止时间点恰好是触发时间点时仍会触发
+	inclusive := Bounded(schedule, from, time.Date(2026, 11, 3, 9, 0, 0, 0, time.UTC))
+	if next := inclusive.Next(time.Date(2026, 11, 3, 8, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2026, 11, 3, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected fire time at until, actual %v", next)
+	}
+	if Bounded(schedule, time.Time{}, time.Time{}) != schedule {
+		t.Errorf("unbounded schedule should not be wrapped")
+	}
+	if !completesAfterRun(Bounded(FixedDelay(from, time.Minute), from, until)) {
+		t.Errorf("bounded fixed delay schedule should complete after run")
+	}
+}
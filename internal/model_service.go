@@ -18,16 +18,20 @@
 package internal
 
 import (
+	"time"
+
 	"gojob/internal/icron"
 	"gojob/models"
 	"gojob/util/dateutil"
+
+	"github.com/pkg/errors"
 )
 
 func InsertJob(job *models.Job) error {
 	job.Id = GetSnowId()
 	job.Status = models.JobStatusOk
 	job.CreateTime = dateutil.NowMillisecond()
-	job.TimeStep = icron.GetTimeStep(job.Cron)
+	job.TimeStep = getTimeStep(job)
 	err := models.CascadeInsertJob(job)
 	if err != nil {
 		return err
@@ -70,9 +74,11 @@ func DeleteJob(id uint64) error {
 func UpdateJob(job *models.Job) error {
 	cronChanged := false
 	refer, _ := models.GetJob(job.Id)
-	if refer.Cron != job.Cron {
+	if refer.Cron != job.Cron || refer.Timezone != job.Timezone || refer.GetTriggerType() != job.GetTriggerType() ||
+		refer.TriggerTime != job.TriggerTime || refer.Interval != job.Interval ||
+		refer.EffectiveFrom != job.EffectiveFrom || refer.EffectiveUntil != job.EffectiveUntil {
 		cronChanged = true
-		job.TimeStep = icron.GetTimeStep(job.Cron)
+		job.TimeStep = getTimeStep(job)
 	}
 
 	err := models.UpdateJob(job)
@@ -103,6 +109,9 @@ func UpdateJobStatus(id uint64, status int) error {
 	if err != nil {
 		return err
 	}
+	if models.JobStatusOk == status && job.IsExpiredAt(time.Now().Unix()) {
+		return errors.Errorf("作业已超过有效期截止时间，请先修改有效期")
+	}
 	job.Status = status
 	err = models.UpdateJob(job)
 	if err != nil {
@@ -115,6 +124,12 @@ func UpdateJobStatus(id uint64, status int) error {
 	if models.JobStatusOk == status {
 		scheduleTask(id)
 	}
+	if models.JobStatusFinished == status || models.JobStatusExpired == status {
+		if sch, exist := getScheduler(id); exist {
+			sch.Stop()
+		}
+		disarmExpiry(id)
+	}
 
 	if IsClusterMode() {
 		err = SubmitCommand(&RaftCommand{
@@ -126,6 +141,17 @@ func UpdateJobStatus(id uint64, status int) error {
 	return err
 }
 
+// 计算作业两次触发的时间间隔
+func getTimeStep(job *models.Job) int64 {
+	switch job.GetTriggerType() {
+	case models.TriggerTypeCron:
+		return icron.GetTimeStep(job.Cron, job.Timezone)
+	case models.TriggerTypeFixedRate, models.TriggerTypeFixedDelay:
+		return int64(job.Interval)
+	}
+	return 0
+}
+
 func updateTriggered(jobId uint64, prev int64, next int64) error {
 	triggered, err := models.GetTriggered(jobId)
 	if err != nil {
@@ -259,3 +285,79 @@ func UpdateAlarmConfig(alarmConfig *models.AlarmConfig) error {
 
 	return err
 }
+
+func InsertCalendar(calendar *models.Calendar) error {
+	calendar.Id = GetSnowId()
+	return SaveCalendar(calendar)
+}
+
+func SaveCalendar(calendar *models.Calendar) error {
+	calendar.UpdateTime = dateutil.NowMillisecond()
+	err := models.SaveCalendar(calendar)
+	if err != nil {
+		return err
+	}
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type:     commandTypeSaveCalendar,
+			Calendar: calendar,
+		})
+	}
+
+	return err
+}
+
+func DeleteCalendar(id uint64) error {
+	err := models.DeleteCalendar(id)
+	if err != nil {
+		return err
+	}
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type:     commandTypeDeleteCalendar,
+			EntityId: id,
+		})
+	}
+
+	return err
+}
+
+func InsertExecutorGroup(group *models.ExecutorGroup) error {
+	group.Id = GetSnowId()
+	return SaveExecutorGroup(group)
+}
+
+func SaveExecutorGroup(group *models.ExecutorGroup) error {
+	group.UpdateTime = dateutil.NowMillisecond()
+	err := models.SaveExecutorGroup(group)
+	if err != nil {
+		return err
+	}
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type:  commandTypeSaveExecutorGroup,
+			Group: group,
+		})
+	}
+
+	return err
+}
+
+func DeleteExecutorGroup(id uint64) error {
+	err := models.DeleteExecutorGroup(id)
+	if err != nil {
+		return err
+	}
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type:     commandTypeDeleteExecutorGroup,
+			EntityId: id,
+		})
+	}
+
+	return err
+}
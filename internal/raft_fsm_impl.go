@@ -39,6 +39,12 @@ const (
 	commandTypeDeleteUser             uint8 = 42
 	commandTypeSaveAlarmConfig        uint8 = 51
 	commandTypeNegationRaftFirstStart uint8 = 61
+	commandTypeSaveCalendar           uint8 = 71
+	commandTypeDeleteCalendar         uint8 = 72
+	commandTypeSaveExecutorInstance   uint8 = 81
+	commandTypeDeleteExecutorInstance uint8 = 82
+	commandTypeSaveExecutorGroup      uint8 = 91
+	commandTypeDeleteExecutorGroup    uint8 = 92
 )
 
 type RaftSnapshot struct {
@@ -48,6 +54,9 @@ type RaftSnapshot struct {
 	Node        []*models.Node
 	User        []*models.User
 	AlarmConfig *models.AlarmConfig
+	Calendar    []*models.Calendar
+	Registry    []*models.ExecutorInstance
+	Group       []*models.ExecutorGroup
 }
 
 type RaftCommand struct {
@@ -58,6 +67,9 @@ type RaftCommand struct {
 	Node        *models.Node
 	User        *models.User
 	AlarmConfig *models.AlarmConfig
+	Calendar    *models.Calendar
+	Instance    *models.ExecutorInstance
+	Group       *models.ExecutorGroup
 	Snapshot    *RaftSnapshot
 }
 
@@ -113,6 +125,28 @@ func (this *FsmImpl) Apply(entry *raft.Log) interface{} {
 		alarmConfig := command.AlarmConfig
 		logs.Infof("Raft Command: 更新AlarmConfig(%v)", alarmConfig.SysAlarmEmail)
 		models.SaveAlarmConfig(alarmConfig)
+	case commandTypeSaveCalendar:
+		calendar := command.Calendar
+		logs.Infof("Raft Command: 更新Calendar(%v)", calendar.Id)
+		models.SaveCalendar(calendar)
+	case commandTypeDeleteCalendar:
+		logs.Infof("Raft Command: 删除Calendar(%v)", command.EntityId)
+		models.DeleteCalendar(command.EntityId)
+	case commandTypeSaveExecutorInstance:
+		instance := command.Instance
+		logs.Infof("Raft Command: 更新ExecutorInstance(%v - %v)", instance.AppName, instance.Address)
+		models.SaveExecutorInstance(instance)
+	case commandTypeDeleteExecutorInstance:
+		instance := command.Instance
+		logs.Infof("Raft Command: 删除ExecutorInstance(%v - %v)", instance.AppName, instance.Address)
+		models.DeleteExecutorInstance(instance.AppName, instance.Address)
+	case commandTypeSaveExecutorGroup:
+		group := command.Group
+		logs.Infof("Raft Command: 更新ExecutorGroup(%v)", group.Id)
+		models.SaveExecutorGroup(group)
+	case commandTypeDeleteExecutorGroup:
+		logs.Infof("Raft Command: 删除ExecutorGroup(%v)", command.EntityId)
+		models.DeleteExecutorGroup(command.EntityId)
 	case commandTypeNegationRaftFirstStart:
 		logs.Info("Raft Command: NegationRaftFirstStart")
 		models.NegationRaftFirstStart()
@@ -141,6 +175,9 @@ func (this *FsmImpl) Restore(readable io.ReadCloser) error {
 		models.BatchSaveNode(snapshot.Node)
 		models.BatchSaveUser(snapshot.User)
 		models.SaveAlarmConfig(snapshot.AlarmConfig)
+		models.BatchSaveCalendar(snapshot.Calendar)
+		models.BatchSaveExecutorInstance(snapshot.Registry)
+		models.BatchSaveExecutorGroup(snapshot.Group)
 		models.UpdateSnapshotVersion(snapshot.Version)
 	} else {
 		logs.Infof("不需要恢复版本为%v的快照", snapshot.Version)
@@ -207,6 +244,21 @@ func createRaftSnapshot() (*RaftSnapshot, error) {
 		return nil, err
 	}
 
+	calendars, err := models.ForEachCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := models.ForEachExecutorInstance()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := models.ForEachExecutorGroup()
+	if err != nil {
+		return nil, err
+	}
+
 	return &RaftSnapshot{
 		Version:     uint64(dateutil.NowMillisecond()),
 		Job:         jobs,
@@ -214,6 +266,9 @@ func createRaftSnapshot() (*RaftSnapshot, error) {
 		Node:        nodes,
 		User:        users,
 		AlarmConfig: alarmConfig,
+		Calendar:    calendars,
+		Registry:    instances,
+		Group:       groups,
 	}, nil
 }
 
@@ -267,6 +322,36 @@ func initRaftCommands() {
 		})
 	}
 
+	calendars, err := models.ForEachCalendar()
+	if err == nil {
+		for _, calendar := range calendars {
+			SubmitCommand(&RaftCommand{
+				Type:     commandTypeSaveCalendar,
+				Calendar: calendar,
+			})
+		}
+	}
+
+	instances, err := models.ForEachExecutorInstance()
+	if err == nil {
+		for _, instance := range instances {
+			SubmitCommand(&RaftCommand{
+				Type:     commandTypeSaveExecutorInstance,
+				Instance: instance,
+			})
+		}
+	}
+
+	groups, err := models.ForEachExecutorGroup()
+	if err == nil {
+		for _, group := range groups {
+			SubmitCommand(&RaftCommand{
+				Type:  commandTypeSaveExecutorGroup,
+				Group: group,
+			})
+		}
+	}
+
 	SubmitCommand(&RaftCommand{
 		Type: commandTypeNegationRaftFirstStart,
 	})
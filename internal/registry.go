@@ -0,0 +1,224 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// 清理过期注册信息的间隔
+	registryCleanInterval = 10 * time.Minute
+	// 心跳停止超过此时间的注册信息被删除
+	registryRetention = time.Hour
+)
+
+// 执行节点的最近一次心跳时间(毫秒)，key为 应用名称|地址；
+// 心跳频繁，只保存在主节点的内存中，不经过Raft也不写入存储
+var executorHeartbeats sync.Map
+
+func executorHeartbeatKey(appName string, address string) string {
+	return appName + "|" + address
+}
+
+// 执行节点注册，已注册且心跳正常的实例保留注册时间
+func RegisterExecutor(instance *models.ExecutorInstance) error {
+	now := dateutil.NowMillisecond()
+	instance.RegisterTime = now
+	if exist, ok := models.GetExecutorInstance(instance.AppName, instance.Address); ok && refreshHeartbeat(exist, now).Alive {
+		instance.RegisterTime = exist.RegisterTime
+	}
+	instance.HeartbeatTime = now
+	instance.Alive = false
+	err := models.SaveExecutorInstance(instance)
+	if err != nil {
+		return err
+	}
+	executorHeartbeats.Store(executorHeartbeatKey(instance.AppName, instance.Address), now)
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type:     commandTypeSaveExecutorInstance,
+			Instance: instance,
+		})
+	}
+
+	return err
+}
+
+// 执行节点心跳续约，只更新主节点内存中的心跳时间；未注册的实例需要重新注册
+func HeartbeatExecutor(appName string, address string) error {
+	if _, ok := models.GetExecutorInstance(appName, address); !ok {
+		return errors.Errorf("执行节点未注册")
+	}
+	executorHeartbeats.Store(executorHeartbeatKey(appName, address), dateutil.NowMillisecond())
+	return nil
+}
+
+// 执行节点注销，通常在执行节点停机前调用
+func UnregisterExecutor(appName string, address string) error {
+	err := models.DeleteExecutorInstance(appName, address)
+	if err != nil {
+		return err
+	}
+	executorHeartbeats.Delete(executorHeartbeatKey(appName, address))
+
+	if IsClusterMode() {
+		return SubmitCommand(&RaftCommand{
+			Type: commandTypeDeleteExecutorInstance,
+			Instance: &models.ExecutorInstance{
+				AppName: appName,
+				Address: address,
+			},
+		})
+	}
+
+	return err
+}
+
+// 用内存中的心跳时间刷新实例的心跳时间和在线状态
+func refreshHeartbeat(instance *models.ExecutorInstance, now int64) *models.ExecutorInstance {
+	if v, ok := executorHeartbeats.Load(executorHeartbeatKey(instance.AppName, instance.Address)); ok {
+		if heartbeatTime := v.(int64); heartbeatTime > instance.HeartbeatTime {
+			instance.HeartbeatTime = heartbeatTime
+		}
+	}
+	instance.Alive = instance.IsAlive(now)
+	return instance
+}
+
+// 成为主节点(或单机启动)时，已注册的实例视为刚刚收到心跳，
+// 在一个心跳超时时间内等待它们向新的主节点续约
+func initExecutorHeartbeats() {
+	instances, err := models.ForEachExecutorInstance()
+	if err != nil {
+		return
+	}
+	now := dateutil.NowMillisecond()
+	for _, instance := range instances {
+		executorHeartbeats.Store(executorHeartbeatKey(instance.AppName, instance.Address), now)
+	}
+}
+
+// 不再是主节点时清空内存中的心跳
+func clearExecutorHeartbeats() {
+	executorHeartbeats.Range(func(key, value interface{}) bool {
+		executorHeartbeats.Delete(key)
+		return true
+	})
+}
+
+// 按应用名称查询执行节点实例，心跳时间和在线状态以主节点内存中的心跳为准
+func SelectExecutorInstanceList(appName string) []*models.ExecutorInstance {
+	now := dateutil.NowMillisecond()
+	list := models.SelectExecutorInstanceList(appName)
+	for _, instance := range list {
+		refreshHeartbeat(instance, now)
+	}
+	return list
+}
+
+// 获取应用下心跳正常的执行节点实例
+func selectAliveExecutorInstances(appName string) []*models.ExecutorInstance {
+	now := dateutil.NowMillisecond()
+	list := make([]*models.ExecutorInstance, 0)
+	for _, instance := range models.SelectAppExecutorInstances(appName) {
+		if refreshHeartbeat(instance, now).Alive {
+			list = append(list, instance)
+		}
+	}
+	return list
+}
+
+// 定期删除心跳长时间停止的注册信息，只在单机或主节点上执行
+func StartRegistryCleanTask() {
+	ticker := time.NewTicker(registryCleanInterval)
+	go func(ticker *time.Ticker) {
+		for {
+			<-ticker.C
+			if !IsStandaloneOrLeader() {
+				continue
+			}
+			instances, err := models.ForEachExecutorInstance()
+			if err != nil {
+				continue
+			}
+			now := dateutil.NowMillisecond()
+			deadline := now - int64(registryRetention/time.Millisecond)
+			for _, instance := range instances {
+				if refreshHeartbeat(instance, now).HeartbeatTime < deadline {
+					logs.Infof("删除心跳停止的执行节点：%s - %s", instance.AppName, instance.Address)
+					UnregisterExecutor(instance.AppName, instance.Address)
+				}
+			}
+		}
+	}(ticker)
+}
+
+// 获取作业的候选执行节点，地址相同的只保留一个：
+// 作业上配置的在线执行节点、引用的执行器组中的在线执行节点、作业及执行器组的应用下心跳正常的注册实例；
+// 每次触发时重新获取，执行器组和注册实例的变化在下一次触发时生效
+func jobExecutors(job *models.Job) []*models.Executor {
+	executors := make([]*models.Executor, 0, len(job.Executors))
+	addresses := make(map[string]bool)
+	addExecutors := func(list []*models.Executor) {
+		for _, v := range list {
+			if models.ExecutorStatusOk == v.Status && !addresses[v.Address] {
+				addresses[v.Address] = true
+				executors = append(executors, v)
+			}
+		}
+	}
+	addInstances := func(appName string) {
+		if "" == appName {
+			return
+		}
+		for _, instance := range selectAliveExecutorInstances(appName) {
+			if !addresses[instance.Address] {
+				addresses[instance.Address] = true
+				executors = append(executors, &models.Executor{
+					Address: instance.Address,
+					Weight:  instance.Weight,
+					Status:  models.ExecutorStatusOk,
+					Labels:  instance.Labels,
+				})
+			}
+		}
+	}
+
+	addExecutors(job.Executors)
+	if "" != job.ExecutorGroupId {
+		group, err := models.GetExecutorGroup(stringutil.ToUintSafe(job.ExecutorGroupId))
+		if nil != err {
+			logs.Warnf("Job(%s)引用的执行器组(%s)不存在", job.Name, job.ExecutorGroupId)
+		} else {
+			addExecutors(group.Executors)
+			addInstances(group.AppName)
+		}
+	}
+	addInstances(job.AppName)
+	return executors
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"gojob/conf"
+	"gojob/internal/bl"
 	"gojob/models"
 	"gojob/util/dateutil"
 	"gojob/util/logs"
@@ -191,16 +192,19 @@ func clusterAlarmNecessary(sysAlarmEmail string) {
 }
 
 type Runtime struct {
-	RunMode            string `json:"runMode"`            // 运行模式
-	StartTime          string `json:"startTime"`          // 启动时间
-	ClusterNodeCount   int    `json:"clusterNodeCount"`   // 集群节点数量
-	JobCount           int    `json:"jobCount"`           // Job数量
-	ExecuteNodeCount   int    `json:"executeNodeCount"`   // 执行节点数量
-	TriggerTimes       int64  `json:"triggerTimes"`       // 调度次数
-	UsableDBAmount     int    `json:"usableDBAmount"`     // 可用数据库数量
-	DisabledDBAmount   int    `json:"disabledDBAmount"`   // 不可用数据库数量
-	UsableNodeAmount   int    `json:"usableNodeAmount"`   // 可用节点数量
-	DisabledNodeAmount int    `json:"disabledNodeAmount"` // 不可用节点数量
+	RunMode            string             `json:"runMode"`            // 运行模式
+	StartTime          string             `json:"startTime"`          // 启动时间
+	ClusterNodeCount   int                `json:"clusterNodeCount"`   // 集群节点数量
+	JobCount           int                `json:"jobCount"`           // Job数量
+	ExecuteNodeCount   int                `json:"executeNodeCount"`   // 执行节点数量
+	TriggerTimes       int64              `json:"triggerTimes"`       // 调度次数
+	UsableDBAmount     int                `json:"usableDBAmount"`     // 可用数据库数量
+	DisabledDBAmount   int                `json:"disabledDBAmount"`   // 不可用数据库数量
+	UsableNodeAmount   int                `json:"usableNodeAmount"`   // 可用节点数量
+	DisabledNodeAmount int                `json:"disabledNodeAmount"` // 不可用节点数量
+	ExecutorStats      []*bl.ExecutorStat `json:"executorStats"`      // 执行节点统计
+	ExecutorBreakers   []*ExecutorBreaker `json:"executorBreakers"`   // 执行节点熔断状态
+	Throttles          []*ThrottleStat    `json:"throttles"`          // 限流器状态，包含排队中的请求数
 }
 
 type RuntimeClusterNode struct {
@@ -221,6 +225,9 @@ func GetRuntime() *Runtime {
 	r.ExecuteNodeCount = models.GetExecutorAmount()
 	r.TriggerTimes = models.GetTriggeredAmount()
 	r.UsableDBAmount, r.DisabledDBAmount = models.GetDBAmount()
+	r.ExecutorStats = bl.ExecutorStats()
+	r.ExecutorBreakers = GetExecutorBreakers()
+	r.Throttles = GetThrottleStats()
 	return r
 }
 
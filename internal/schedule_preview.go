@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"gojob/internal/icron"
+	"gojob/models"
+	"gojob/util/dateutil"
+)
+
+const (
+	minPreviewCount      = 10
+	maxPreviewCount      = 50
+	previewTimeFormatter = "2006-01-02 15:04:05 -07:00"
+)
+
+// 调度预览VO
+type SchedulePreview struct {
+	Timezone     string   `json:"timezone"`     // 时区
+	FireTimes    []string `json:"fireTimes"`    // 触发时间点（作业时区）
+	TimeStep     int64    `json:"timeStep"`     // 计算出的触发间隔（秒）
+	ConstantStep bool     `json:"constantStep"` // 触发间隔是否固定
+	MinStep      int64    `json:"minStep"`      // 最小触发间隔（秒）
+	MaxStep      int64    `json:"maxStep"`      // 最大触发间隔（秒）
+	Warnings     []string `json:"warnings"`     // 警告信息
+}
+
+// 预览作业接下来的触发时间点，count限定在10~50之间
+func PreviewSchedule(job *models.Job, count int) (*SchedulePreview, error) {
+	if count < minPreviewCount {
+		count = minPreviewCount
+	}
+	if count > maxPreviewCount {
+		count = maxPreviewCount
+	}
+
+	location, err := icron.LoadLocation(job.Timezone)
+	if nil != err {
+		return nil, err
+	}
+	schedule, err := jobSchedule(job)
+	if nil != err {
+		return nil, err
+	}
+	now := time.Now()
+	preview := icron.PreviewSchedule(schedule, location, now, count)
+
+	vo := &SchedulePreview{
+		Timezone:     job.Timezone,
+		FireTimes:    make([]string, 0, len(preview.FireTimes)),
+		TimeStep:     getTimeStep(job),
+		ConstantStep: preview.IsConstantStep(),
+		MinStep:      preview.MinStep,
+		MaxStep:      preview.MaxStep,
+		Warnings:     make([]string, 0),
+	}
+	if "" == vo.Timezone {
+		vo.Timezone = time.Local.String()
+	}
+	for _, fireTime := range preview.FireTimes {
+		vo.FireTimes = append(vo.FireTimes, dateutil.Layout(fireTime, previewTimeFormatter))
+	}
+
+	if !vo.ConstantStep {
+		vo.Warnings = append(vo.Warnings, fmt.Sprintf("触发间隔不固定(%d秒~%d秒)，计算出的间隔%d秒仅为参考值", vo.MinStep, vo.MaxStep, vo.TimeStep))
+	}
+	if job.EffectiveFrom > now.Unix() {
+		vo.Warnings = append(vo.Warnings, fmt.Sprintf("有效期自%s开始，之前不会触发", formatPreviewTime(job.EffectiveFrom, location)))
+	}
+	if job.EffectiveUntil > 0 && len(vo.FireTimes) < count {
+		if job.IsExpiredAt(now.Unix()) {
+			vo.Warnings = append(vo.Warnings, fmt.Sprintf("有效期已于%s截止，不会再触发", formatPreviewTime(job.EffectiveUntil, location)))
+		} else {
+			vo.Warnings = append(vo.Warnings, fmt.Sprintf("有效期截止于%s，之后不再触发", formatPreviewTime(job.EffectiveUntil, location)))
+		}
+	}
+	if window := executionWindow(job); window > 0 && vo.MinStep > 0 && vo.MinStep < window {
+		vo.Warnings = append(vo.Warnings, fmt.Sprintf("最小触发间隔%d秒小于超时与重试所需的%d秒，前一次执行可能尚未结束", vo.MinStep, window))
+	}
+
+	return vo, nil
+}
+
+// 一次执行在超时与重试下可能占用的最长时间（秒），不超过执行期限
+func executionWindow(job *models.Job) int64 {
+	if job.Timeout <= 0 {
+		return int64(job.ExecuteDeadline)
+	}
+	policy := GetRetryPolicy(job)
+	window := time.Duration(job.Timeout) * time.Second * time.Duration(job.RetryCount+1)
+	for i := 1; i <= job.RetryCount; i++ {
+		window = window + policy.Interval(i)
+	}
+	seconds := int64(window / time.Second)
+	if job.ExecuteDeadline > 0 && int64(job.ExecuteDeadline) < seconds {
+		return int64(job.ExecuteDeadline)
+	}
+	return seconds
+}
+
+func formatPreviewTime(timestamp int64, location *time.Location) string {
+	return dateutil.Layout(time.Unix(timestamp, 0).In(location), previewTimeFormatter)
+}
@@ -18,6 +18,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -32,6 +33,7 @@ import (
 	"gojob/util/stringutil"
 
 	"github.com/pkg/errors"
+	"github.com/robfig/cron"
 )
 
 const detailLineSeparator = "<line>"
@@ -39,15 +41,23 @@ const detailLineSeparator = "<line>"
 var schedulerMap map[uint64]*icron.Scheduler = make(map[uint64]*icron.Scheduler)
 var roundLoadBalances map[uint64]bl.LoadBalance = make(map[uint64]bl.LoadBalance)
 var weightRoundLoadBalances map[uint64]bl.LoadBalance = make(map[uint64]bl.LoadBalance)
+var weightRandomLoadBalances map[uint64]bl.LoadBalance = make(map[uint64]bl.LoadBalance)
+var consistentHashLoadBalances map[uint64]*bl.ConsistentHashLoadBalance = make(map[uint64]*bl.ConsistentHashLoadBalance)
 var randomLoadBalance bl.LoadBalance
-var weightRandomLoadBalance bl.LoadBalance
+var leastActiveLoadBalance bl.LoadBalance
+var responseTimeLoadBalance bl.LoadBalance
 var schedulerMapLock sync.Mutex
 
+// 作业级负载均衡器的锁，与 schedulerMapLock 分开，避免停止调度器时与执行中的任务互相等待
+var loadBalanceLock sync.Mutex
+
 // 初始化任务调度器
 func InitSchedulers() {
 	log.Print("启动 任务调度器")
 	randomLoadBalance = bl.NewRandomLoadBalance()
-	weightRandomLoadBalance = bl.NewWeightRandomLoadBalance()
+	leastActiveLoadBalance = bl.NewLeastActiveLoadBalance()
+	responseTimeLoadBalance = bl.NewResponseTimeLoadBalance()
+	initExecutorHeartbeats()
 	jobs, err := models.ForEachJob()
 	if err != nil {
 		logs.Errorf("查询任务列表失败：%s", err.Error())
@@ -68,6 +78,7 @@ func InitSchedulers() {
 		}
 	}
 	log.Printf("初始化任务数量: %d", count)
+	recoverAsyncExecutions()
 	scanMisfires()
 }
 
@@ -82,7 +93,12 @@ func deleteSchedulers() {
 			cron.Stop()
 		}
 		delete(schedulerMap, jobId)
+		removeLoadBalances(jobId)
 	}
+	clearAsyncExecutions()
+	clearDeferredExecutions()
+	clearExpiries()
+	clearExecutorHeartbeats()
 }
 
 func existScheduler(jobId uint64) bool {
@@ -109,22 +125,73 @@ func addScheduler(job *models.Job) error {
 	schedulerMapLock.Lock()
 	defer schedulerMapLock.Unlock()
 
-	roundLoadBalances[job.Id] = bl.NewRoundLoadBalance()
-	weightRoundLoadBalances[job.Id] = bl.NewWeightRoundLoadBalance()
+	addLoadBalances(job.Id)
 	task := newTask(job)
-	cron, err := icron.NewJobScheduler(job.Cron, task)
+	schedule, err := newSchedule(job)
 	if err != nil {
 		logs.Errorf("Job(%s)创建调度器失败：%s", job.Name, err.Error())
 		return err
 	}
-	schedulerMap[job.Id] = cron
+	schedulerMap[job.Id] = icron.ScheduleJob(schedule, task)
 	logs.Infof("Job(%s)成功创建调度器", job.Name)
 	return nil
 }
 
+// 创建作业的调度计划
+// 一次性作业的触发时间已过却从未触发(如领导者切换期间错过)时，改为立即触发
+func newSchedule(job *models.Job) (cron.Schedule, error) {
+	if models.TriggerTypeOnce == job.GetTriggerType() && job.TriggerTime <= time.Now().Unix() {
+		if td, err := models.GetTriggered(job.Id); nil == err && 0 == td.PrevTime {
+			logs.Infof("Job(%s)错过了一次性触发时间，立即补发", job.Name)
+			return boundJobSchedule(job, icron.Once(time.Now().Add(time.Second))), nil
+		}
+	}
+	return jobSchedule(job)
+}
+
+// 获取作业的调度计划，只在有效期内触发
+func jobSchedule(job *models.Job) (cron.Schedule, error) {
+	var schedule cron.Schedule
+	switch job.GetTriggerType() {
+	case models.TriggerTypeCron:
+		spec, err := icron.ParseSpec(job.Cron, job.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		schedule = spec
+	case models.TriggerTypeOnce:
+		schedule = icron.Once(time.Unix(job.TriggerTime, 0))
+	case models.TriggerTypeFixedRate:
+		schedule = icron.FixedRate(time.Unix(job.TriggerTime, 0), time.Duration(job.Interval)*time.Second)
+	case models.TriggerTypeFixedDelay:
+		schedule = icron.FixedDelay(time.Unix(job.TriggerTime, 0), time.Duration(job.Interval)*time.Second)
+	default:
+		return nil, errors.Errorf("不支持的触发类型：%s", job.TriggerType)
+	}
+	return boundJobSchedule(job, schedule), nil
+}
+
+// 将调度计划限定在作业的有效期内
+func boundJobSchedule(job *models.Job, schedule cron.Schedule) cron.Schedule {
+	var from, until time.Time
+	if job.EffectiveFrom > 0 {
+		from = time.Unix(job.EffectiveFrom, 0)
+	}
+	if job.EffectiveUntil > 0 {
+		until = time.Unix(job.EffectiveUntil, 0)
+	}
+	return icron.Bounded(schedule, from, until)
+}
+
 // 启动
 func scheduleTask(jobId uint64) {
 	cron, exist := schedulerMap[jobId]
+	if job, err := models.GetJob(jobId); nil == err {
+		armExpiry(job)
+		if job.IsExpiredAt(time.Now().Unix()) {
+			return
+		}
+	}
 	td, err := models.GetTriggered(jobId)
 	if exist && err == nil {
 		if 0 == td.NextTime {
@@ -142,6 +209,7 @@ func suspendTask(jobId uint64) {
 	if exist {
 		cron.Stop()
 	}
+	disarmExpiry(jobId)
 	updateTriggered(jobId, 0, 0)
 	logs.Infof("挂起任务：%v", jobId)
 }
@@ -153,9 +221,59 @@ func cancelTask(jobId uint64) {
 
 	suspendTask(jobId)
 	delete(schedulerMap, jobId)
+	removeLoadBalances(jobId)
 	logs.Infof("取消任务：%v", jobId)
 }
 
+// 创建作业级的负载均衡器
+func addLoadBalances(jobId uint64) {
+	loadBalanceLock.Lock()
+	defer loadBalanceLock.Unlock()
+
+	roundLoadBalances[jobId] = bl.NewRoundLoadBalance()
+	weightRoundLoadBalances[jobId] = bl.NewWeightRoundLoadBalance()
+	weightRandomLoadBalances[jobId] = bl.NewWeightRandomLoadBalance()
+	consistentHashLoadBalances[jobId] = bl.NewConsistentHashLoadBalance(bl.DefaultVirtualNodes)
+}
+
+// 删除作业级的负载均衡器
+func removeLoadBalances(jobId uint64) {
+	loadBalanceLock.Lock()
+	defer loadBalanceLock.Unlock()
+
+	delete(roundLoadBalances, jobId)
+	delete(weightRoundLoadBalances, jobId)
+	delete(weightRandomLoadBalances, jobId)
+	delete(consistentHashLoadBalances, jobId)
+}
+
+// 获取作业按选择策略对应的负载均衡器
+func getLoadBalance(strategy string, jobId uint64) (bl.LoadBalance, bool) {
+	loadBalanceLock.Lock()
+	defer loadBalanceLock.Unlock()
+
+	var lb bl.LoadBalance
+	var exist bool
+	switch strategy {
+	case models.ExecutorSelectStrategyRound:
+		lb, exist = roundLoadBalances[jobId]
+	case models.ExecutorSelectStrategyWeightRandom:
+		lb, exist = weightRandomLoadBalances[jobId]
+	case models.ExecutorSelectStrategyWeightRound:
+		lb, exist = weightRoundLoadBalances[jobId]
+	}
+	return lb, exist
+}
+
+// 获取作业的一致性哈希负载均衡器
+func getConsistentHashLoadBalance(jobId uint64) (*bl.ConsistentHashLoadBalance, bool) {
+	loadBalanceLock.Lock()
+	defer loadBalanceLock.Unlock()
+
+	lb, exist := consistentHashLoadBalances[jobId]
+	return lb, exist
+}
+
 // 手动触发任务
 func LaunchTask(jobId uint64) error {
 	job, err := models.GetJob(jobId)
@@ -176,12 +294,7 @@ func LaunchTask(jobId uint64) error {
 		return errors.Errorf("任务类型转换错误")
 	}
 
-	ctx := &scheduleContext{
-		job:          job,
-		scheduleType: models.ScheduleTypeManual,
-		startTime:    time.Now().Unix(),
-		details:      make([]string, 0),
-	}
+	ctx := newScheduleContext(job, models.ScheduleTypeManual, time.Now().Unix())
 	logs.Infof("手动执行:%s", jobId)
 	ctx.detail("手动执行")
 	go httpTask.doRun(ctx)
@@ -191,61 +304,107 @@ func LaunchTask(jobId uint64) error {
 
 // 调度上下文
 type scheduleContext struct {
-	scheduleType int         // 调度类型
-	startTime    int64       // 调度开始时间
-	lock         sync.Mutex  // 互斥锁
-	details      []string    // 详细信息
-	job          *models.Job // 作业
+	traceId        uint64      // 调度跟踪主键，同时作为执行ID
+	scheduleType   int         // 调度类型
+	startTime      int64       // 调度开始时间
+	lock           sync.Mutex  // 互斥锁
+	details        []string    // 详细信息
+	job            *models.Job // 作业
+	responseBody   string      // 响应内容
+	responseFailed bool        // 记录的响应内容是否来自失败的请求
+	running        bool        // 是否已记录为执行中
+	context        context.Context
+	cancelFunc     context.CancelFunc
+	cancelReason   string               // 取消原因
+	dispatched     []string             // 已发送请求的执行节点地址
+	shards         []*models.ShardTrace // 分片执行记录
+	shardsSaved    bool                 // 分片执行记录是否已保存
+	deadline       time.Time            // 执行期限，覆盖重试和故障转移
+	throttled      string               // 限流原因
 }
 
-func (this *scheduleContext) succeed() {
-	trace := models.Trace{
-		Id:            GetSnowId(),
-		JobId:         this.job.Id,
-		JobName:       this.job.Name,
-		ScheduleType:  this.scheduleType,
-		StartTime:     this.startTime,
-		EndTime:       time.Now().Unix(),
-		ExecuteStatus: models.ExecuteStatusSucceed,
-		ExecuteResult: "执行成功",
+func newScheduleContext(job *models.Job, scheduleType int, startTime int64) *scheduleContext {
+	execCtx, cancelFunc := context.WithCancel(context.Background())
+	return &scheduleContext{
+		traceId:      GetSnowId(),
+		job:          job,
+		scheduleType: scheduleType,
+		startTime:    startTime,
+		details:      make([]string, 0),
+		context:      execCtx,
+		cancelFunc:   cancelFunc,
 	}
+}
 
-	// 需要触发子任务
-	if len(this.job.SubJobIds) > 0 &&
-		(models.SubJobScheduleStrategyEnd == this.job.SubJobScheduleStrategy ||
-			models.SubJobScheduleStrategyOk == this.job.SubJobScheduleStrategy) {
-		this.detail(fmt.Sprintf("开始触发子任务，子任务数量:%d", len(this.job.SubJobIds)))
-		go this.launchSubTask()
-	}
+// 受并发策略限制跳过执行
+func (this *scheduleContext) skipped(reason string) {
+	this.finish(models.ExecuteStatusSkipped, reason)
+}
 
-	trace.ExecuteDetail = strings.Join(this.details, detailLineSeparator)
-	models.InsertTrace(&trace)
+func (this *scheduleContext) succeed() {
+	this.finish(models.ExecuteStatusSucceed, "执行成功")
 }
 
 func (this *scheduleContext) failed(reason string) {
-	trace := models.Trace{
-		Id:            GetSnowId(),
+	this.finish(models.ExecuteStatusFailed, reason)
+}
+
+// 记录为执行中，等待执行节点回调
+func (this *scheduleContext) await() {
+	trace := this.newTrace(models.ExecuteStatusRunning, "执行中，等待执行节点回调")
+	trace.EndTime = 0
+	this.running = true
+	models.InsertTrace(trace)
+	this.saveShards(models.ExecuteStatusRunning)
+}
+
+func (this *scheduleContext) newTrace(status int, reason string) *models.Trace {
+	return &models.Trace{
+		Id:            this.traceId,
 		JobId:         this.job.Id,
 		JobName:       this.job.Name,
 		ScheduleType:  this.scheduleType,
 		StartTime:     this.startTime,
 		EndTime:       time.Now().Unix(),
-		ExecuteStatus: models.ExecuteStatusFailed,
+		ExecuteStatus: status,
 		ExecuteResult: reason,
+		ExecuteDetail: strings.Join(this.details, detailLineSeparator),
+		ResponseBody:  this.responseBody,
+	}
+}
+
+func (this *scheduleContext) finish(status int, reason string) {
+	releaseExecution(this)
+	removeAsyncExecution(this.traceId)
+	this.cancelFunc()
+	if cancelled, cancelReason := this.isCancelled(); cancelled && models.ExecuteStatusFailed == status {
+		status = models.ExecuteStatusCancelled
+		reason = cancelReason
 	}
 
 	// 需要触发子任务
 	if len(this.job.SubJobIds) > 0 &&
-		(models.SubJobScheduleStrategyEnd == this.job.SubJobScheduleStrategy ||
-			models.SubJobScheduleStrategyFail == this.job.SubJobScheduleStrategy) {
+		((models.SubJobScheduleStrategyEnd == this.job.SubJobScheduleStrategy &&
+			(models.ExecuteStatusSucceed == status || models.ExecuteStatusFailed == status)) ||
+			(models.SubJobScheduleStrategyOk == this.job.SubJobScheduleStrategy && models.ExecuteStatusSucceed == status) ||
+			(models.SubJobScheduleStrategyFail == this.job.SubJobScheduleStrategy && models.ExecuteStatusFailed == status)) {
 		this.detail(fmt.Sprintf("开始触发子任务，子任务数量:%d", len(this.job.SubJobIds)))
 		go this.launchSubTask()
 	}
 
-	trace.ExecuteDetail = strings.Join(this.details, detailLineSeparator)
+	// 一次性作业执行成功后标记为已完成
+	if models.ExecuteStatusSucceed == status && models.TriggerTypeOnce == this.job.GetTriggerType() {
+		if err := UpdateJobStatus(this.job.Id, models.JobStatusFinished); nil != err {
+			logs.Errorf("Job(%s)标记为已完成失败：%s", this.job.Name, err.Error())
+		} else {
+			this.detail("一次性作业执行成功，标记为已完成")
+		}
+	}
+
+	trace := this.newTrace(status, reason)
 
 	// 需要告警
-	if "" != this.job.AlarmEmail {
+	if models.ExecuteStatusFailed == status && "" != this.job.AlarmEmail {
 		models.SendAlarmEmail(&models.AlarmEmail{
 			Toers:   this.job.AlarmEmail,
 			Subject: fmt.Sprintf("Go-Job告警,任务(%s)执行失败。", this.job.Name),
@@ -254,7 +413,105 @@ func (this *scheduleContext) failed(reason string) {
 		this.detail("发送告警邮件")
 	}
 
-	models.InsertTrace(&trace)
+	if this.running {
+		models.UpdateTraceResult(trace)
+	} else {
+		models.InsertTrace(trace)
+	}
+	this.saveShards(status)
+}
+
+// 取消执行，中断正在进行的HTTP请求，不再重试和故障转移
+func (this *scheduleContext) cancel(reason string) {
+	this.lock.Lock()
+	this.cancelReason = reason
+	dispatched := make([]string, len(this.dispatched))
+	copy(dispatched, this.dispatched)
+	this.lock.Unlock()
+
+	this.cancelFunc()
+	this.mutexDetail(fmt.Sprintf("取消执行：%s", reason))
+	if "" != this.job.StopUri && len(dispatched) > 0 {
+		go notifyExecutorStop(this.job, this.traceId, dispatched)
+	}
+	if nil != removeAwaitingAsyncExecution(this.traceId) {
+		this.failed(reason)
+	}
+}
+
+// 开始计算执行期限
+func (this *scheduleContext) startDeadline() {
+	if this.job.ExecuteDeadline > 0 {
+		this.deadline = time.Now().Add(time.Duration(this.job.ExecuteDeadline) * time.Second)
+	}
+}
+
+// 是否已超过执行期限
+func (this *scheduleContext) expired() bool {
+	return !this.deadline.IsZero() && time.Now().After(this.deadline)
+}
+
+// 已取消或者超过执行期限，不再重试和故障转移
+func (this *scheduleContext) stopped() bool {
+	return this.cancelled() || this.expired()
+}
+
+// 发送请求使用的上下文，设置了执行期限时到期自动中断请求
+func (this *scheduleContext) requestContext() (context.Context, context.CancelFunc) {
+	if this.deadline.IsZero() {
+		return this.context, func() {}
+	}
+	return context.WithDeadline(this.context, this.deadline)
+}
+
+// 请求被限流
+func (this *scheduleContext) mutexThrottled(reason string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.throttled = reason
+	this.detail(fmt.Sprintf("请求被限流(throttled)：%s", reason))
+}
+
+// 执行节点请求失败
+func (this *scheduleContext) dispatchFailed() {
+	this.lock.Lock()
+	throttled := this.throttled
+	this.lock.Unlock()
+	if "" != throttled {
+		this.failed(fmt.Sprintf("执行失败，请求被限流(throttled)：%s", throttled))
+		return
+	}
+	if this.expired() {
+		this.failed(fmt.Sprintf("执行失败，超过执行期限%d秒", this.job.ExecuteDeadline))
+		return
+	}
+	this.failed("执行失败")
+}
+
+func (this *scheduleContext) cancelled() bool {
+	cancelled, _ := this.isCancelled()
+	return cancelled
+}
+
+// 记录已发送请求的执行节点
+func (this *scheduleContext) mutexDispatched(address string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, v := range this.dispatched {
+		if v == address {
+			return
+		}
+	}
+	this.dispatched = append(this.dispatched, address)
+}
+
+func (this *scheduleContext) isCancelled() (bool, string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return "" != this.cancelReason, this.cancelReason
 }
 
 func (this *scheduleContext) detail(msg string) {
@@ -271,6 +528,22 @@ func (this *scheduleContext) mutexDetail(msg string) {
 	this.detail(msg)
 }
 
+// 记录执行节点的响应内容并标明执行节点和分片；广播、分片和故障转移时保留第一个失败的响应
+func (this *scheduleContext) mutexResponseBody(node *executeNode, body string, succeed bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.responseFailed {
+		return
+	}
+	label := node.address
+	if nil != node.shard {
+		label = fmt.Sprintf("%s 分片(%s)", node.address, node.shard.ShardParam)
+	}
+	this.responseBody = stringutil.Truncate(label+"："+body, responseBodyTraceLimit)
+	this.responseFailed = !succeed
+}
+
 func (this *scheduleContext) launchSubTask() {
 	for _, v := range this.job.SubJobIds {
 		subJobId := stringutil.ToUintSafe(v)
@@ -293,12 +566,7 @@ func (this *scheduleContext) launchSubTask() {
 			continue
 		}
 
-		ctx := &scheduleContext{
-			job:          subJob,
-			scheduleType: models.ScheduleTypeDepend,
-			startTime:    time.Now().Unix(),
-			details:      make([]string, 0),
-		}
+		ctx := newScheduleContext(subJob, models.ScheduleTypeDepend, time.Now().Unix())
 
 		logs.Infof("调度子任务:%s", subJob.Name)
 		ctx.detail(fmt.Sprintf("子任务触发，父任务名称:%s", this.job.Name))
@@ -306,11 +574,90 @@ func (this *scheduleContext) launchSubTask() {
 	}
 }
 
+const (
+	// 触发时间点过去多久(秒)仍未触发才视为错发；等待工作协程的触发由调度器排除，不依赖此时长
+	misfireGracePeriod = 1
+	// 单个作业一次最多找出的错发时间点数量
+	maxMisfireTimes = 1000
+)
+
 var processingMisfires sync.Map
 
+// 错发信息
+type misfireInfo struct {
+	Triggered *models.Triggered // 实时触发信息
+	Job       *models.Job       // 作业
+	FireTimes []int64           // 错过的触发时间点，按时间先后排列
+}
+
+// 根据作业的调度计划找出上次触发之后、当前时间之前错过且未超过misfire超时时间的触发时间点
+func selectMisfires() []*misfireInfo {
+	current := time.Now()
+	list := make([]*misfireInfo, 0)
+	triggereds, err := models.ForEachTriggered()
+	if nil != err {
+		return list
+	}
+	for _, entity := range triggereds {
+		if entity.PrevTime == 0 && entity.NextTime == 0 {
+			continue
+		}
+		job, err := models.GetJob(entity.Id)
+		if nil != err {
+			continue
+		}
+		if job.Status != models.JobStatusOk {
+			continue
+		}
+		// 一次性作业错过的触发在创建调度器时补发
+		if job.GetTriggerType() == models.TriggerTypeOnce {
+			continue
+		}
+		// 固定延迟作业的下次触发取决于上次执行结束的时间，没有可以补偿的固定时间点
+		if job.GetTriggerType() == models.TriggerTypeFixedDelay {
+			continue
+		}
+		if job.MisfireThreshold == 0 {
+			continue
+		}
+
+		// 上次触发之后的时间点才可能错过；从未触发过时，从记录的下次触发时间开始
+		after := entity.PrevTime
+		if 0 == after {
+			after = entity.NextTime - 1
+		}
+		if earliest := current.Unix() - job.MisfireThreshold - 1; after < earliest {
+			after = earliest
+		}
+		until := current.Unix() - misfireGracePeriod
+		if after >= until {
+			continue
+		}
+
+		schedule, err := jobSchedule(job)
+		if nil != err {
+			continue
+		}
+		missed := icron.ScheduleMissedTimes(schedule, time.Unix(after, 0), time.Unix(until, 0), maxMisfireTimes)
+		if len(missed) == 0 {
+			continue
+		}
+		fireTimes := make([]int64, 0, len(missed))
+		for _, t := range missed {
+			fireTimes = append(fireTimes, t.Unix())
+		}
+		list = append(list, &misfireInfo{
+			Triggered: entity,
+			Job:       job,
+			FireTimes: fireTimes,
+		})
+	}
+	return list
+}
+
 // 扫描错发任务
 func scanMisfires() {
-	misfires := models.SelectMisfireList()
+	misfires := selectMisfires()
 	if len(misfires) == 0 {
 		logs.Infof("无Misfire任务")
 		return
@@ -318,23 +665,21 @@ func scanMisfires() {
 
 	logs.Infof("Misfire任务数量：%d", len(misfires))
 	for _, misfire := range misfires {
-		if _, exist := processingMisfires.Load(misfire.Id); !exist {
-			processingMisfires.Store(misfire.Id, true)
+		if _, exist := processingMisfires.Load(misfire.Job.Id); !exist {
+			processingMisfires.Store(misfire.Job.Id, true)
 			go handleMisfire(misfire)
 		}
 	}
 }
 
-func handleMisfire(triggered *models.Triggered) {
-	job, err := models.GetJob(triggered.Id)
-	if err != nil {
-		logs.Errorf("任务调度失败,查找Job信息错误:%s", err.Error())
-		return
-	}
+// 按作业的错发策略处理错过的触发时间点
+func handleMisfire(misfire *misfireInfo) {
+	defer processingMisfires.Delete(misfire.Job.Id)
 
-	sch, exist := schedulerMap[triggered.Id]
+	job := misfire.Job
+	sch, exist := getScheduler(job.Id)
 	if !exist {
-		logs.Errorf("任务调度失败,未找到Job(%v)的调度器:%s", triggered.Id)
+		logs.Errorf("任务调度失败,未找到Job(%v)的调度器", job.Id)
 		return
 	}
 
@@ -345,20 +690,66 @@ func handleMisfire(triggered *models.Triggered) {
 		return
 	}
 
-	past := triggered.NextTime
+	// 仍在等待工作协程或正在执行的触发不是错发，补偿会导致重复执行
+	fireTimes := misfire.FireTimes
+	if unfinished := sch.GetUnfinishedTime(); unfinished > 0 {
+		for i, fireTime := range fireTimes {
+			if fireTime >= unfinished {
+				fireTimes = fireTimes[:i]
+				break
+			}
+		}
+	}
+	if len(fireTimes) == 0 {
+		return
+	}
+
 	startTime := time.Now().Unix()
-	nextTime := past + job.TimeStep
-	updateTriggered(triggered.Id, startTime, nextTime)
+	updateTriggered(job.Id, startTime, sch.GetNextTime())
 
-	ctx := &scheduleContext{
-		job:          job,
-		scheduleType: models.ScheduleTypeCompensation,
-		startTime:    startTime,
-		details:      make([]string, 0),
+	fireTimes = filterCalendarTimes(job, fireTimes)
+	if len(fireTimes) == 0 {
+		return
+	}
+	missed := make([]string, 0, len(fireTimes))
+	for _, fireTime := range fireTimes {
+		missed = append(missed, formatFireTime(job, fireTime))
 	}
+	logs.Infof("Job(%s)错过的执行时间点为：%s，错发策略：%s", job.Name, strings.Join(missed, ","), job.GetMisfirePolicy())
 
-	logs.Infof(fmt.Sprintf("补偿执行,被补偿的执行时间点为：%s", dateutil.Layout(time.Unix(past, 0), dateutil.DayTimeSecondFormatter)))
-	ctx.detail(fmt.Sprintf("补偿执行,被补偿的执行时间点为：%s", dateutil.Layout(time.Unix(past, 0), dateutil.DayTimeSecondFormatter)))
-	httpTask.doRun(ctx)
-	processingMisfires.Delete(triggered.Id)
+	if models.MisfirePolicySkip == job.GetMisfirePolicy() {
+		ctx := newScheduleContext(job, models.ScheduleTypeCompensation, startTime)
+		ctx.skipped(fmt.Sprintf("错过%d个执行时间点(%s)，按错发策略不补偿", len(missed), strings.Join(missed, ",")))
+		return
+	}
+	// 补偿执行的当前时间同样受日历约束
+	if httpTask.applyCalendars(job, time.Unix(startTime, 0), models.ScheduleTypeCompensation) {
+		return
+	}
+
+	switch job.GetMisfirePolicy() {
+	case models.MisfirePolicyFireAll:
+		if len(fireTimes) > job.GetMisfireMaxFires() {
+			logs.Infof("Job(%s)错过%d个执行时间点，只补偿最近的%d个", job.Name, len(fireTimes), job.GetMisfireMaxFires())
+			fireTimes = fireTimes[len(fireTimes)-job.GetMisfireMaxFires():]
+		}
+		for i, fireTime := range fireTimes {
+			ctx := newScheduleContext(job, models.ScheduleTypeCompensation, time.Now().Unix())
+			ctx.detail(fmt.Sprintf("补偿执行(%d/%d),被补偿的执行时间点为：%s", i+1, len(fireTimes), formatFireTime(job, fireTime)))
+			httpTask.doRun(ctx)
+		}
+	default:
+		ctx := newScheduleContext(job, models.ScheduleTypeCompensation, startTime)
+		ctx.detail(fmt.Sprintf("补偿执行,被补偿的执行时间点为：%s", strings.Join(missed, ",")))
+		httpTask.doRun(ctx)
+	}
+}
+
+// 按作业时区格式化触发时间点
+func formatFireTime(job *models.Job, fireTime int64) string {
+	t := time.Unix(fireTime, 0)
+	if location, err := icron.LoadLocation(job.Timezone); nil == err {
+		t = t.In(location)
+	}
+	return dateutil.Layout(t, dateutil.DayTimeSecondFormatter)
 }
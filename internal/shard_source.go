@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gojob/internal/icron"
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/httputil"
+	"gojob/util/stringutil"
+
+	"github.com/pkg/errors"
+)
+
+// 调度跟踪中每行分片列表的最大字节数
+const shardListLineLimit = 90
+
+// 按作业的分片来源获取本次执行的分片列表，动态来源的分片列表记录在调度跟踪中
+func (this *HttpTask) resolveShardParams(ctx *scheduleContext) ([]string, error) {
+	var params []string
+	switch ctx.job.GetShardSource() {
+	case models.ShardSourceHttp:
+		list, err := fetchShardParams(ctx)
+		if nil != err {
+			return nil, err
+		}
+		params = list
+	case models.ShardSourceDate:
+		dateRange, err := models.ParseShardDateRange(ctx.job.ShardDateRange)
+		if nil != err {
+			return nil, err
+		}
+		location, err := icron.LoadLocation(ctx.job.Timezone)
+		if nil != err {
+			return nil, err
+		}
+		params = dateRange.Generate(time.Unix(ctx.startTime, 0).In(location), ctx.job.GetShardDateFormat())
+	default:
+		if ctx.job.ShardingParam != "" {
+			return strings.Split(ctx.job.ShardingParam, ","), nil
+		}
+		params = make([]string, ctx.job.ShardingCount)
+		for i := 0; i < ctx.job.ShardingCount; i++ {
+			params[i] = strconv.Itoa(i)
+		}
+		return params, nil
+	}
+
+	if len(params) == 0 {
+		return nil, errors.Errorf("分片来源返回的分片列表为空")
+	}
+	ctx.detail(fmt.Sprintf("分片来源：%s，分片列表共%d个", ctx.job.GetShardSource(), len(params)))
+	line := ""
+	for _, param := range params {
+		if "" != line && len(line)+len(param)+1 > shardListLineLimit {
+			ctx.detail("分片列表：" + line)
+			line = ""
+		}
+		if "" != line {
+			line = line + ","
+		}
+		line = line + param
+	}
+	ctx.detail("分片列表：" + line)
+	return params, nil
+}
+
+// 调用分片来源接口获取分片列表
+func fetchShardParams(ctx *scheduleContext) ([]string, error) {
+	sourceUrl := ctx.job.ShardSourceUrl
+	executionId := stringutil.UintToStr(ctx.traceId)
+	request := httputil.NewHttpClient().SetTimeout(ctx.job.Timeout).
+		NewRequest().
+		SetContext(ctx.context).
+		AddHeader("X-Job-Id", stringutil.UintToStr(ctx.job.Id)).
+		AddHeader("X-Execution-Id", executionId)
+	if models.HttpSignEnabled == ctx.job.HttpSign {
+		requestUrl, err := url.Parse(sourceUrl)
+		if nil != err {
+			return nil, err
+		}
+		timestamp := strconv.FormatInt(dateutil.NowMillisecond(), 10)
+		request.AddHeader("X-Timestamp", timestamp)
+		request.AddHeader("X-Sign", Signature(requestUrl.RequestURI()+timestamp))
+	}
+	res, err := request.Get(sourceUrl)
+	if nil != err {
+		return nil, errors.Errorf("请求分片来源接口错误：%s", err.Error())
+	}
+	defer res.Body.Close()
+	if 200 != res.StatusCode {
+		return nil, errors.Errorf("分片来源接口返回StatusCode：%v", res.StatusCode)
+	}
+	// 多读一个字节判断是否超限，截断的分片列表不能继续使用
+	body, err := httputil.ReadBody(res, responseBodyReadLimit+1)
+	if nil != err {
+		return nil, errors.Errorf("读取分片来源接口响应错误：%s", err.Error())
+	}
+	if len(body) > responseBodyReadLimit {
+		return nil, errors.Errorf("分片来源接口响应超过%d字节", responseBodyReadLimit)
+	}
+	return models.ParseShardList(body)
+}
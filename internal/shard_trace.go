@@ -0,0 +1,235 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/logs"
+	"gojob/util/syncutil"
+
+	"github.com/pkg/errors"
+)
+
+// 创建分片执行记录
+func (this *scheduleContext) newShard(parameter string) *models.ShardTrace {
+	shard := &models.ShardTrace{
+		Id:            GetSnowId(),
+		TraceId:       this.traceId,
+		JobId:         this.job.Id,
+		ShardParam:    parameter,
+		ExecuteStatus: models.ExecuteStatusFailed,
+		StartTime:     dateutil.NowMillisecond(),
+	}
+	this.lock.Lock()
+	this.shards = append(this.shards, shard)
+	this.lock.Unlock()
+	return shard
+}
+
+// 收到执行节点回调，更新该执行节点上执行中的分片；回调带有分片参数时按分片参数匹配
+func (this *scheduleContext) completeShard(address string, sharding string, succeed bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, shard := range this.shards {
+		if shard.Executor == address && models.ExecuteStatusRunning == shard.ExecuteStatus &&
+			("" == sharding || shard.ShardParam == sharding) {
+			shard.Duration = dateutil.NowMillisecond() - shard.StartTime
+			if succeed {
+				shard.ExecuteStatus = models.ExecuteStatusSucceed
+			} else {
+				shard.ExecuteStatus = models.ExecuteStatusFailed
+			}
+			return
+		}
+	}
+}
+
+// 保存分片执行记录；调度结束时仍在执行中的分片记为失败
+func (this *scheduleContext) saveShards(status int) {
+	this.lock.Lock()
+	if len(this.shards) == 0 {
+		this.lock.Unlock()
+		return
+	}
+	if models.ExecuteStatusRunning != status && models.ExecuteStatusSucceed != status {
+		for _, shard := range this.shards {
+			if models.ExecuteStatusRunning == shard.ExecuteStatus {
+				shard.ExecuteStatus = models.ExecuteStatusFailed
+				shard.Duration = dateutil.NowMillisecond() - shard.StartTime
+			}
+		}
+	}
+	shards := make([]*models.ShardTrace, len(this.shards))
+	for i, shard := range this.shards {
+		temp := *shard
+		shards[i] = &temp
+	}
+	saved := this.shardsSaved
+	this.shardsSaved = true
+	this.lock.Unlock()
+
+	var err error
+	if saved {
+		err = models.UpdateShardTraceResults(shards)
+	} else {
+		err = models.InsertShardTraces(shards)
+	}
+	if nil != err {
+		logs.Errorf("Job(%s)保存分片执行记录失败：%s", this.job.Name, err.Error())
+	}
+}
+
+// 执行分片并记录执行节点、执行次数、状态和耗时
+func (this *HttpTask) executeShard(ctx *scheduleContext, executeNode *executeNode) bool {
+	shard := executeNode.shard
+	if nil == shard {
+		return this.doExecute(ctx, executeNode)
+	}
+
+	// 发送请求前标记为执行中，请求返回前收到的回调也能匹配到分片
+	ctx.lock.Lock()
+	shard.Executor = executeNode.address
+	shard.Attempts = shard.Attempts + 1
+	shard.ExecuteStatus = models.ExecuteStatusRunning
+	ctx.lock.Unlock()
+
+	begin := time.Now()
+	succeed := this.doExecute(ctx, executeNode)
+
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
+	if models.ExecuteStatusRunning != shard.ExecuteStatus {
+		// 请求返回前已收到执行节点回调
+		return succeed
+	}
+	shard.Duration = shard.Duration + int64(time.Since(begin)/time.Millisecond)
+	if !succeed {
+		shard.ExecuteStatus = models.ExecuteStatusFailed
+	} else if !ctx.job.IsAsync() {
+		shard.ExecuteStatus = models.ExecuteStatusSucceed
+	}
+	// 异步执行的分片保持执行中，等待执行节点回调
+	return succeed
+}
+
+// 重跑调度中执行失败的分片，只分发失败的分片参数，按分片故障转移的逻辑选择执行节点
+func RerunFailedShards(traceId uint64) error {
+	trace, err := models.GetTrace(traceId)
+	if nil != err {
+		return err
+	}
+	if nil == trace {
+		return errors.Errorf("调度跟踪信息不存在")
+	}
+	if models.ExecuteStatusFailed != trace.ExecuteStatus && models.ExecuteStatusCancelled != trace.ExecuteStatus {
+		return errors.Errorf("只能重跑执行失败的调度")
+	}
+	shards, err := models.SelectShardTraces(traceId)
+	if nil != err {
+		return err
+	}
+	failedShards := make([]*models.ShardTrace, 0)
+	for _, shard := range shards {
+		if models.ExecuteStatusSucceed != shard.ExecuteStatus {
+			failedShards = append(failedShards, shard)
+		}
+	}
+	if len(failedShards) == 0 {
+		return errors.Errorf("没有失败的分片")
+	}
+
+	job, err := models.GetJob(trace.JobId)
+	if nil != err {
+		return err
+	}
+	if models.ExecutorSelectStrategySharding != job.ExecutorSelectStrategy {
+		return errors.Errorf("作业不是分片执行")
+	}
+	sch, exist := getScheduler(job.Id)
+	if !exist {
+		return errors.Errorf("未找到调度器")
+	}
+	httpTask, succeed := sch.GetJob().(*HttpTask)
+	if !succeed {
+		return errors.Errorf("任务类型转换错误")
+	}
+
+	ctx := newScheduleContext(job, models.ScheduleTypeRerunShards, time.Now().Unix())
+	logs.Infof("Job(%s)重跑失败分片，原调度：%v", job.Name, traceId)
+	ctx.detail(fmt.Sprintf("重跑失败分片，原调度：%v，失败分片数量：%d", traceId, len(failedShards)))
+	go httpTask.rerunShards(ctx, failedShards)
+
+	return nil
+}
+
+func (this *HttpTask) rerunShards(ctx *scheduleContext, failedShards []*models.ShardTrace) {
+	if acquired, reason := acquireExecution(ctx); !acquired {
+		logs.Infof("Job(%s)跳过执行：%s", ctx.job.Name, reason)
+		ctx.skipped(reason)
+		return
+	}
+	ctx.startDeadline()
+	if ctx.job.IsAsync() {
+		prepareAsyncExecution(ctx)
+	}
+
+	executeNodes, ok := this.prepareExecuteNodes(ctx)
+	if !ok {
+		return
+	}
+
+	// 原执行节点之外没有可用的执行节点时，允许在原执行节点上重跑
+	excluded := 0
+	for _, node := range executeNodes {
+		for _, shard := range failedShards {
+			if node.address == shard.Executor {
+				excluded++
+				break
+			}
+		}
+	}
+	exclude := excluded < len(executeNodes)
+	if !exclude {
+		ctx.detail("无其他可用执行节点，在原执行节点上重跑")
+	}
+
+	failedNodes := syncutil.NewMutexSlice()
+	for _, shard := range failedShards {
+		ctx.detail(fmt.Sprintf("失败分片: %s - %s", shard.Executor, shard.ShardParam))
+		node := &executeNode{
+			parameter: shard.ShardParam,
+			shard:     ctx.newShard(shard.ShardParam),
+		}
+		if exclude {
+			node.address = shard.Executor
+		}
+		failedNodes.Add(node)
+	}
+
+	if this.shardingTakeover(ctx, executeNodes, failedNodes) {
+		this.complete(ctx, len(failedShards), len(failedShards))
+	} else {
+		ctx.dispatchFailed()
+	}
+}
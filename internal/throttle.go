@@ -0,0 +1,298 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gojob/conf"
+	"gojob/models"
+	"gojob/util/stringutil"
+)
+
+// 默认的最长排队等待时间（秒）
+const defaultThrottleWait = 10
+
+// 限流器，限制同时发送的请求数(并发)和每秒发送的请求数(令牌桶，容量为每秒的请求数)
+type limiter struct {
+	name        string
+	concurrency int
+	rate        int
+	slots       chan struct{} // 并发许可，不限制并发时为nil
+	lock        sync.Mutex
+	tokens      float64   // 剩余令牌，为负数时表示已被排队的请求预留
+	last        time.Time // 上次补充令牌的时间
+	active      int64     // 执行中的请求数
+	waiting     int64     // 排队中的请求数
+	throttled   int64     // 排队超时被拒绝的请求数
+}
+
+// 限流器状态快照
+type ThrottleStat struct {
+	Name        string `json:"name"`        // 限流对象
+	Concurrency int    `json:"concurrency"` // 并发限制，0为不限制
+	Rate        int    `json:"rate"`        // 每秒请求数限制，0为不限制
+	Active      int64  `json:"active"`      // 执行中的请求数
+	Waiting     int64  `json:"waiting"`     // 排队中的请求数
+	Throttled   int64  `json:"throttled"`   // 排队超时被拒绝的请求数
+}
+
+var throttleWait = defaultThrottleWait * time.Second
+var throttleConfig = &conf.ThrottleConfig{}
+var globalLimiter *limiter
+var limiters = make(map[string]*limiter)
+var limitersLock sync.Mutex
+
+// 初始化限流参数
+func InitThrottle(config *conf.ThrottleConfig) {
+	if nil == config {
+		return
+	}
+	throttleConfig = config
+	if config.Wait > 0 {
+		throttleWait = time.Duration(config.Wait) * time.Second
+	}
+	if config.DispatchConcurrency > 0 || config.DispatchRate > 0 {
+		globalLimiter = newLimiter("全局", config.DispatchConcurrency, config.DispatchRate)
+	}
+}
+
+func newLimiter(name string, concurrency int, rate int) *limiter {
+	l := &limiter{
+		name:        name,
+		concurrency: concurrency,
+		rate:        rate,
+		tokens:      float64(rate),
+		last:        time.Now(),
+	}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	return l
+}
+
+// 预留一个令牌，返回需要等待的时间；需要等待的时间超过maxWait时不预留
+func (this *limiter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	if this.rate <= 0 {
+		return 0, true
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.tokens = this.tokens + now.Sub(this.last).Seconds()*float64(this.rate)
+	if this.tokens > float64(this.rate) {
+		this.tokens = float64(this.rate)
+	}
+	this.last = now
+	var wait time.Duration
+	if this.tokens < 1 {
+		wait = time.Duration((1 - this.tokens) / float64(this.rate) * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	this.tokens = this.tokens - 1
+	return wait, true
+}
+
+// 在期限内获取一个令牌，不限制每秒请求数时立即返回
+func (this *limiter) take(ctx context.Context, deadline time.Time) bool {
+	if this.rate <= 0 {
+		return true
+	}
+	atomic.AddInt64(&this.waiting, 1)
+	defer atomic.AddInt64(&this.waiting, -1)
+
+	wait, reserved := this.reserve(time.Now(), time.Until(deadline))
+	if !reserved {
+		return false
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// 在期限内获取并发许可，不限制并发时立即返回
+func (this *limiter) hold(ctx context.Context, deadline time.Time) bool {
+	if nil != this.slots {
+		atomic.AddInt64(&this.waiting, 1)
+		defer atomic.AddInt64(&this.waiting, -1)
+
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case this.slots <- struct{}{}:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	atomic.AddInt64(&this.active, 1)
+	return true
+}
+
+func (this *limiter) release() {
+	atomic.AddInt64(&this.active, -1)
+	if nil != this.slots {
+		<-this.slots
+	}
+}
+
+func (this *limiter) stat() *ThrottleStat {
+	return &ThrottleStat{
+		Name:        this.name,
+		Concurrency: this.concurrency,
+		Rate:        this.rate,
+		Active:      atomic.LoadInt64(&this.active),
+		Waiting:     atomic.LoadInt64(&this.waiting),
+		Throttled:   atomic.LoadInt64(&this.throttled),
+	}
+}
+
+// 获取限流器，限制参数变化时重建；不限制时返回nil
+func getLimiter(key string, name string, concurrency int, rate int) *limiter {
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+
+	l, exist := limiters[key]
+	if concurrency <= 0 && rate <= 0 {
+		if exist {
+			delete(limiters, key)
+		}
+		return nil
+	}
+	if !exist || l.concurrency != concurrency || l.rate != rate {
+		l = newLimiter(name, concurrency, rate)
+		limiters[key] = l
+	}
+	return l
+}
+
+// 作业向执行节点发送请求需要经过的限流器，范围小的在前：执行节点、执行器组、全局；
+// 按此顺序获取许可，等待某个执行节点时不会占用执行器组和全局的许可
+func dispatchLimiters(job *models.Job, address string) []*limiter {
+	result := make([]*limiter, 0, 3)
+	concurrency, rate := throttleConfig.ExecutorConcurrency, throttleConfig.ExecutorRate
+	for _, v := range throttleConfig.Executors {
+		if nil != v && v.Address == address {
+			concurrency, rate = v.Concurrency, v.Rate
+			break
+		}
+	}
+	if l := getLimiter("executor:"+address, "执行节点"+address, concurrency, rate); nil != l {
+		result = append(result, l)
+	}
+	if "" != job.ExecutorGroupId {
+		if group, err := models.GetExecutorGroup(stringutil.ToUintSafe(job.ExecutorGroupId)); nil == err && nil != group {
+			if l := getLimiter("group:"+job.ExecutorGroupId, "执行器组"+group.Name, group.MaxConcurrency, group.RateLimit); nil != l {
+				result = append(result, l)
+			}
+		}
+	}
+	if nil != globalLimiter {
+		result = append(result, globalLimiter)
+	}
+	return result
+}
+
+// 发送请求的许可：并发许可在整个请求(包括重试)期间持有，令牌在每次请求尝试前获取
+type dispatchPermit struct {
+	limiters []*limiter
+	held     []*limiter
+}
+
+// 排队等待的期限，不超过请求的期限
+func throttleDeadline(ctx context.Context, start time.Time) time.Time {
+	deadline := start.Add(throttleWait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	return deadline
+}
+
+func throttledReason(l *limiter, start time.Time, deadline time.Time) string {
+	atomic.AddInt64(&l.throttled, 1)
+	return fmt.Sprintf("%s排队等待超过%v", l.name, deadline.Sub(start).Round(time.Millisecond))
+}
+
+// 获取发送请求的并发许可，超过限制时排队等待；等待超过期限时返回限流原因
+func acquireDispatch(ctx context.Context, job *models.Job, address string) (*dispatchPermit, string) {
+	start := time.Now()
+	deadline := throttleDeadline(ctx, start)
+	permit := &dispatchPermit{
+		limiters: dispatchLimiters(job, address),
+	}
+	for _, l := range permit.limiters {
+		if !l.hold(ctx, deadline) {
+			permit.release()
+			return nil, throttledReason(l, start, deadline)
+		}
+		permit.held = append(permit.held, l)
+	}
+	return permit, ""
+}
+
+// 每次请求尝试前获取令牌，超过每秒请求数时排队等待；等待超过期限时返回限流原因
+func (this *dispatchPermit) take(ctx context.Context) string {
+	start := time.Now()
+	deadline := throttleDeadline(ctx, start)
+	for _, l := range this.limiters {
+		if !l.take(ctx, deadline) {
+			return throttledReason(l, start, deadline)
+		}
+	}
+	return ""
+}
+
+// 释放并发许可
+func (this *dispatchPermit) release() {
+	for _, l := range this.held {
+		l.release()
+	}
+	this.held = nil
+}
+
+// 获取所有限流器的状态，全局限流器在前，其余按名称排序
+func GetThrottleStats() []*ThrottleStat {
+	result := make([]*ThrottleStat, 0)
+	limitersLock.Lock()
+	for _, l := range limiters {
+		result = append(result, l.stat())
+	}
+	limitersLock.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	if nil != globalLimiter {
+		result = append([]*ThrottleStat{globalLimiter.stat()}, result...)
+	}
+	return result
+}
@@ -25,6 +25,7 @@ import (
 
 	"gojob/conf"
 	"gojob/internal"
+	"gojob/internal/icron"
 	"gojob/models"
 	"gojob/routes"
 	"gojob/util/logs"
@@ -51,6 +52,9 @@ func main() {
 	models.CreateDefaultUserIfNecessary()
 	models.InitXorm(config.DataSourceConfig)
 	models.InitAlarm()
+	icron.InitEngine(config.ScheduleWorkers)
+	internal.InitCircuitBreaker(config.BreakerFailureThreshold, config.BreakerCooldown)
+	internal.InitThrottle(config.ThrottleConfig)
 	if internal.IsClusterMode() {
 		internal.BootstrapCluster(conf.InitClusterConfig(*cc))
 	} else { // 单机
@@ -58,6 +62,8 @@ func main() {
 		internal.InitSchedulers()
 	}
 	internal.StartMonitorTask()
+	internal.StartHealthCheckTask(config.HealthCheckInterval)
+	internal.StartRegistryCleanTask()
 	routes.StartCertificateClearTask()
 	routes.StartRouter(config.HttpServerBind, config.HttpServerPort)
 }
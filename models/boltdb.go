@@ -34,13 +34,16 @@ const (
 )
 
 var (
-	jobBucket         = []byte("job")
-	triggeredBucket   = []byte("triggered")
-	nodeBucket        = []byte("node")
-	userBucket        = []byte("user")
-	alarmConfigBucket = []byte("alarmConfig")
-	envBucket         = []byte("env")
-	boltDB            *bolt.DB
+	jobBucket           = []byte("job")
+	triggeredBucket     = []byte("triggered")
+	nodeBucket          = []byte("node")
+	userBucket          = []byte("user")
+	alarmConfigBucket   = []byte("alarmConfig")
+	envBucket           = []byte("env")
+	calendarBucket      = []byte("calendar")
+	registryBucket      = []byte("registry")
+	executorGroupBucket = []byte("executorGroup")
+	boltDB              *bolt.DB
 )
 
 func InitBoltDB(dataStorePath string) {
@@ -62,6 +65,9 @@ func InitBoltDB(dataStorePath string) {
 		tx.CreateBucketIfNotExists(nodeBucket)
 		tx.CreateBucketIfNotExists(alarmConfigBucket)
 		tx.CreateBucketIfNotExists(envBucket)
+		tx.CreateBucketIfNotExists(calendarBucket)
+		tx.CreateBucketIfNotExists(registryBucket)
+		tx.CreateBucketIfNotExists(executorGroupBucket)
 		return nil
 	})
 	logs.Info("本地存储引擎boltDB创建成功")
@@ -84,6 +90,15 @@ func RestBucket() {
 
 		tx.DeleteBucket(alarmConfigBucket)
 		tx.CreateBucketIfNotExists(alarmConfigBucket)
+
+		tx.DeleteBucket(calendarBucket)
+		tx.CreateBucketIfNotExists(calendarBucket)
+
+		tx.DeleteBucket(registryBucket)
+		tx.CreateBucketIfNotExists(registryBucket)
+
+		tx.DeleteBucket(executorGroupBucket)
+		tx.CreateBucketIfNotExists(executorGroupBucket)
 		return nil
 	})
 }
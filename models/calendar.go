@@ -0,0 +1,302 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gojob/util/byteutil"
+	"gojob/util/dateutil"
+	"gojob/util/stringutil"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	// 日历策略 -- 跳过被排除的触发
+	CalendarActionSkip = "skip"
+	// 日历策略 -- 顺延到排除结束后执行
+	CalendarActionDefer = "defer"
+	// 计算排除结束时间的最大步数，避免日历配置导致长时间循环
+	maxCalendarSteps = 1000
+	// 封锁时段的时间格式
+	clockFormatter = "15:04"
+)
+
+var weekdayNames = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// 封锁时段，按周重复；结束时间不大于开始时间时表示跨越午夜
+type BlackoutWindow struct {
+	Weekdays []int  `json:"weekdays"` // 生效的星期 0周日 1周一 ... 6周六，为空表示每天
+	Start    string `json:"start"`    // 开始时间 HH:mm
+	End      string `json:"end"`      // 结束时间 HH:mm
+}
+
+// 日历
+type Calendar struct {
+	Id               uint64            `json:"-"`                // 主键
+	IdStr            string            `json:"id"`               // 主键
+	Name             string            `json:"name"`             // 日历名称
+	Timezone         string            `json:"timezone"`         // 时区，为空时使用作业的时区
+	BusinessDaysOnly bool              `json:"businessDaysOnly"` // 只在工作日（周一至周五）触发
+	Holidays         []string          `json:"holidays"`         // 节假日 yyyy-MM-dd
+	Workdays         []string          `json:"workdays"`         // 调休的周末工作日 yyyy-MM-dd
+	Blackouts        []*BlackoutWindow `json:"blackouts"`        // 封锁时段
+	Remark           string            `json:"remark"`           // 备注
+	UpdateTime       int64             `json:"updateTime"`       // 更新时间
+}
+
+type CalendarSortableList []*Calendar
+
+func (ls CalendarSortableList) Len() int {
+	return len(ls)
+}
+
+func (ls CalendarSortableList) Less(i, j int) bool {
+	return ls[i].UpdateTime > ls[j].UpdateTime
+}
+
+func (ls CalendarSortableList) Swap(i, j int) {
+	ls[i], ls[j] = ls[j], ls[i]
+}
+
+// 解析HH:mm格式的时间，返回当天的分钟数
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse(clockFormatter, clock)
+	if err != nil {
+		return 0, errors.Errorf("时间格式错误：%s，应为HH:mm", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (this *BlackoutWindow) onWeekday(weekday time.Weekday) bool {
+	if len(this.Weekdays) == 0 {
+		return true
+	}
+	for _, v := range this.Weekdays {
+		if v == int(weekday) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *BlackoutWindow) String() string {
+	days := make([]string, 0, len(this.Weekdays))
+	for _, v := range this.Weekdays {
+		if v >= 0 && v < len(weekdayNames) {
+			days = append(days, weekdayNames[v])
+		}
+	}
+	if len(days) == 0 {
+		days = append(days, "每天")
+	}
+	return fmt.Sprintf("%s %s-%s", strings.Join(days, ","), this.Start, this.End)
+}
+
+// 时间点落在封锁时段内时，返回封锁结束的时间点
+func (this *BlackoutWindow) until(local time.Time) (time.Time, bool) {
+	start, err := ParseClock(this.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := ParseClock(this.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	year, month, day := local.Date()
+	endAt := func(dayOffset int) time.Time {
+		return time.Date(year, month, day+dayOffset, end/60, end%60, 0, 0, local.Location())
+	}
+	if start < end {
+		if this.onWeekday(local.Weekday()) && minute >= start && minute < end {
+			return endAt(0), true
+		}
+		return time.Time{}, false
+	}
+	// 跨越午夜
+	if this.onWeekday(local.Weekday()) && minute >= start {
+		return endAt(1), true
+	}
+	if this.onWeekday(local.AddDate(0, 0, -1).Weekday()) && minute < end {
+		return endAt(0), true
+	}
+	return time.Time{}, false
+}
+
+func (this *Calendar) location(timezone string) *time.Location {
+	if "" != this.Timezone {
+		timezone = this.Timezone
+	}
+	if "" == timezone {
+		return time.Local
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// 判断时间点是否被日历排除，被排除时返回原因和排除结束的时间点
+// timezone为作业的时区，日历未设置时区时使用
+func (this *Calendar) Exclude(t time.Time, timezone string) (string, time.Time) {
+	local := t.In(this.location(timezone))
+	date := local.Format(dateutil.DayFormatter)
+	year, month, day := local.Date()
+	nextDay := time.Date(year, month, day+1, 0, 0, 0, 0, local.Location())
+
+	if stringutil.InArray(date, this.Holidays) {
+		return fmt.Sprintf("日历(%s)：%s为节假日", this.Name, date), nextDay
+	}
+	if this.BusinessDaysOnly && (time.Saturday == local.Weekday() || time.Sunday == local.Weekday()) &&
+		!stringutil.InArray(date, this.Workdays) {
+		return fmt.Sprintf("日历(%s)：%s为非工作日", this.Name, date), nextDay
+	}
+	for _, blackout := range this.Blackouts {
+		if until, ok := blackout.until(local); ok {
+			return fmt.Sprintf("日历(%s)：处于封锁时段%s", this.Name, blackout.String()), until
+		}
+	}
+	return "", time.Time{}
+}
+
+// 判断时间点是否被任意一个日历排除
+func ExcludeByCalendars(calendars []*Calendar, t time.Time, timezone string) (string, time.Time) {
+	for _, calendar := range calendars {
+		if reason, until := calendar.Exclude(t, timezone); "" != reason {
+			return reason, until
+		}
+	}
+	return "", time.Time{}
+}
+
+// 获取不早于t且不被任何日历排除的最早时间点，找不到时返回零值
+func NextIncludedTime(calendars []*Calendar, t time.Time, timezone string) time.Time {
+	for i := 0; i < maxCalendarSteps; i++ {
+		reason, until := ExcludeByCalendars(calendars, t, timezone)
+		if "" == reason {
+			return t
+		}
+		t = until
+	}
+	return time.Time{}
+}
+
+func SaveCalendar(entity *Calendar) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(calendarBucket)
+		bs, err := msgpack.Marshal(entity)
+		if err != nil {
+			return err
+		}
+		return bt.Put(byteutil.Uint64ToBytes(entity.Id), bs)
+	})
+}
+
+func BatchSaveCalendar(entities []*Calendar) error {
+	return GetBoltDB().Batch(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(calendarBucket)
+		for _, entity := range entities {
+			bs, err := msgpack.Marshal(entity)
+			if err != nil {
+				continue
+			}
+			bt.Put(byteutil.Uint64ToBytes(entity.Id), bs)
+		}
+		return nil
+	})
+}
+
+func DeleteCalendar(id uint64) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(calendarBucket)
+		return bt.Delete(byteutil.Uint64ToBytes(id))
+	})
+}
+
+func GetCalendar(id uint64) (*Calendar, error) {
+	var val []byte
+	err := GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(calendarBucket)
+		val = bucket.Get(byteutil.Uint64ToBytes(id))
+		if val == nil {
+			return errors.Errorf("Key Not Found")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entity = new(Calendar)
+	err = msgpack.Unmarshal(val, entity)
+	if err != nil {
+		return nil, err
+	}
+	entity.IdStr = stringutil.UintToStr(entity.Id)
+	return entity, nil
+}
+
+func ForEachCalendar() ([]*Calendar, error) {
+	list := make([]*Calendar, 0)
+	err := GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(calendarBucket)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var entity = new(Calendar)
+			if err := msgpack.Unmarshal(v, entity); err == nil {
+				entity.IdStr = stringutil.UintToStr(entity.Id)
+				list = append(list, entity)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+func SelectCalendarList(name string) []*Calendar {
+	list, _ := ForEachCalendar()
+	filtered := make([]*Calendar, 0, len(list))
+	for _, entity := range list {
+		if "" != name && !strings.Contains(entity.Name, name) {
+			continue
+		}
+		filtered = append(filtered, entity)
+	}
+	sortables := CalendarSortableList(filtered)
+	sort.Sort(sortables)
+	return sortables
+}
+
+// 根据ID获取日历，不存在的日历被忽略
+func SelectCalendars(ids []string) []*Calendar {
+	list := make([]*Calendar, 0, len(ids))
+	for _, id := range ids {
+		if calendar, err := GetCalendar(stringutil.ToUintSafe(id)); nil == err {
+			list = append(list, calendar)
+		}
+	}
+	return list
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarExclude(t *testing.T) {
+	calendar := &Calendar{
+		Name:             "settlement",
+		BusinessDaysOnly: true,
+		Holidays:         []string{"2026-10-01"},
+		Workdays:         []string{"2026-10-10"},
+		Blackouts: []*BlackoutWindow{
+			{Weekdays: []int{6}, Start: "01:00", End: "04:00"},
+			{Start: "23:30", End: "00:30"},
+		},
+	}
+	cases := []struct {
+		at       string
+		excluded bool
+		until    string
+	}{
+		{"2026-10-01 10:00", true, "2026-10-02 00:00"}, // 节假日
+		{"2026-10-02 10:00", false, ""},                // 周五
+		{"2026-10-03 10:00", true, "2026-10-04 00:00"}, // 周六
+		{"2026-10-10 10:00", false, ""},                // 调休的周六
+		{"2026-10-10 02:00", true, "2026-10-10 04:00"}, // 周六封锁时段
+		{"2026-10-10 04:00", false, ""},
+		{"2026-10-08 23:45", true, "2026-10-09 00:30"}, // 跨越午夜的封锁时段
+		{"2026-10-09 00:15", true, "2026-10-09 00:30"},
+	}
+	for _, c := range cases {
+		at, _ := time.ParseInLocation("2006-01-02 15:04", c.at, time.UTC)
+		reason, until := calendar.Exclude(at, "UTC")
+		if c.excluded != ("" != reason) {
+			t.Errorf("%s: expected excluded %v, actual reason %q", c.at, c.excluded, reason)
+			continue
+		}
+		if c.excluded && until.Format("2006-01-02 15:04") != c.until {
+			t.Errorf("%s: expected until %s, actual %s", c.at, c.until, until.Format("2006-01-02 15:04"))
+		}
+	}
+}
+
+func TestNextIncludedTime(t *testing.T) {
+	calendars := []*Calendar{
+		{Name: "holidays", Holidays: []string{"2026-10-01", "2026-10-02"}},
+		{Name: "business", BusinessDaysOnly: true},
+	}
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	next := NextIncludedTime(calendars, at, "UTC")
+	if expected := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected %v, actual %v", expected, next)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err.Error())
+	}
+	at = time.Date(2026, 10, 2, 16, 0, 0, 0, time.UTC) // 东京时间周六01:00
+	calendar := &Calendar{Name: "tokyo", Timezone: "Asia/Tokyo", BusinessDaysOnly: true}
+	if reason, _ := calendar.Exclude(at, "UTC"); "" == reason {
+		t.Errorf("expected excluded in calendar timezone")
+	}
+	if next := NextIncludedTime([]*Calendar{calendar}, at, ""); !next.Equal(time.Date(2026, 10, 5, 0, 0, 0, 0, tokyo)) {
+		t.Errorf("unexpected next included time %v", next)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"gojob/util/byteutil"
+	"gojob/util/stringutil"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+// 执行器组，多个作业可以引用同一个执行器组，修改后在各作业的下一次触发时生效
+type ExecutorGroup struct {
+	Id             uint64      `json:"-"`              // 主键
+	IdStr          string      `json:"id"`             // 主键
+	Name           string      `json:"name"`           // 执行器组名称
+	AppName        string      `json:"appName"`        // 应用名称，该应用下心跳正常的自注册实例也属于此执行器组
+	Executors      []*Executor `json:"executors"`      // 执行器
+	MaxConcurrency int         `json:"maxConcurrency"` // 引用此执行器组的作业同时发送的请求数，0为不限制
+	RateLimit      int         `json:"rateLimit"`      // 引用此执行器组的作业每秒发送的请求数，0为不限制
+	Remark         string      `json:"remark"`         // 备注
+	UpdateTime     int64       `json:"updateTime"`     // 更新时间
+}
+
+type ExecutorGroupSortableList []*ExecutorGroup
+
+func (ls ExecutorGroupSortableList) Len() int {
+	return len(ls)
+}
+
+func (ls ExecutorGroupSortableList) Less(i, j int) bool {
+	return ls[i].UpdateTime > ls[j].UpdateTime
+}
+
+func (ls ExecutorGroupSortableList) Swap(i, j int) {
+	ls[i], ls[j] = ls[j], ls[i]
+}
+
+func SaveExecutorGroup(entity *ExecutorGroup) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(executorGroupBucket)
+		bs, err := msgpack.Marshal(entity)
+		if err != nil {
+			return err
+		}
+		return bt.Put(byteutil.Uint64ToBytes(entity.Id), bs)
+	})
+}
+
+func BatchSaveExecutorGroup(entities []*ExecutorGroup) error {
+	return GetBoltDB().Batch(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(executorGroupBucket)
+		for _, entity := range entities {
+			bs, err := msgpack.Marshal(entity)
+			if err != nil {
+				continue
+			}
+			bt.Put(byteutil.Uint64ToBytes(entity.Id), bs)
+		}
+		return nil
+	})
+}
+
+func DeleteExecutorGroup(id uint64) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(executorGroupBucket)
+		return bt.Delete(byteutil.Uint64ToBytes(id))
+	})
+}
+
+func GetExecutorGroup(id uint64) (*ExecutorGroup, error) {
+	var val []byte
+	err := GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(executorGroupBucket)
+		val = bucket.Get(byteutil.Uint64ToBytes(id))
+		if val == nil {
+			return errors.Errorf("Key Not Found")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entity = new(ExecutorGroup)
+	err = msgpack.Unmarshal(val, entity)
+	if err != nil {
+		return nil, err
+	}
+	entity.IdStr = stringutil.UintToStr(entity.Id)
+	return entity, nil
+}
+
+func ForEachExecutorGroup() ([]*ExecutorGroup, error) {
+	list := make([]*ExecutorGroup, 0)
+	err := GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(executorGroupBucket)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var entity = new(ExecutorGroup)
+			if err := msgpack.Unmarshal(v, entity); err == nil {
+				entity.IdStr = stringutil.UintToStr(entity.Id)
+				list = append(list, entity)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+func SelectExecutorGroupList(name string) []*ExecutorGroup {
+	list, _ := ForEachExecutorGroup()
+	filtered := make([]*ExecutorGroup, 0, len(list))
+	for _, entity := range list {
+		if "" != name && !strings.Contains(entity.Name, name) {
+			continue
+		}
+		filtered = append(filtered, entity)
+	}
+	sortables := ExecutorGroupSortableList(filtered)
+	sort.Sort(sortables)
+	return sortables
+}
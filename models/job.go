@@ -23,6 +23,7 @@ import (
 	"sync"
 
 	"gojob/util/byteutil"
+	"gojob/util/dateutil"
 	"gojob/util/logs"
 	"gojob/util/stringutil"
 
@@ -42,6 +43,36 @@ const (
 	ExecutorSelectStrategyWeightRandom = "weight_random"
 	// 执行节点选择策略 -- 加权轮询
 	ExecutorSelectStrategyWeightRound = "weight_round"
+	// 执行节点选择策略 -- 广播，并行调用所有在线的执行节点
+	ExecutorSelectStrategyBroadcast = "broadcast"
+	// 执行节点选择策略 -- 一致性哈希，同一个哈希键总是选中同一个执行节点
+	ExecutorSelectStrategyConsistentHash = "consistent_hash"
+	// 执行节点选择策略 -- 最少活跃，选择执行中请求最少的执行节点
+	ExecutorSelectStrategyLeastActive = "least_active"
+	// 执行节点选择策略 -- 响应时间加权，选择响应时间EWMA与负载综合最优的执行节点
+	ExecutorSelectStrategyResponseTime = "response_time"
+	// 分片策略 -- 平均分配
+	ShardingStrategyEven = "even"
+	// 分片策略 -- 按执行节点权重比例分配
+	ShardingStrategyWeight = "weight"
+	// 分片策略 -- 粘性分配，按rendezvous hashing分配，分片只在所在执行节点离开或被新加入的执行节点接手时迁移
+	ShardingStrategySticky = "sticky"
+	// 重试间隔策略 -- 固定间隔
+	RetryBackoffFixed = "fixed"
+	// 重试间隔策略 -- 指数退避，每次重试间隔翻倍
+	RetryBackoffExponential = "exponential"
+	// 分片来源 -- 固定的分片参数或分片总数
+	ShardSourceStatic = "static"
+	// 分片来源 -- 执行前调用HTTP接口获取分片列表
+	ShardSourceHttp = "http"
+	// 分片来源 -- 执行前按日期区间生成分片列表
+	ShardSourceDate = "date"
+	// 广播成功规则 -- 全部执行节点成功
+	BroadcastSuccessRuleAll = "all"
+	// 广播成功规则 -- 超过半数执行节点成功
+	BroadcastSuccessRuleQuorum = "quorum"
+	// 广播成功规则 -- 至少一个执行节点成功
+	BroadcastSuccessRuleOne = "one"
 	// 子任务触发策略 -- 执行完毕触发
 	SubJobScheduleStrategyEnd = 0
 	// 子任务触发策略 -- 执行成功触发
@@ -52,19 +83,64 @@ const (
 	JobStatusOk = 1
 	// 作业状态 -- 挂起
 	JobStatusPause = 0
+	// 作业状态 -- 已完成，一次性作业执行成功后不再触发
+	JobStatusFinished = 2
+	// 作业状态 -- 已失效，超过有效期截止时间后自动进入
+	JobStatusExpired = 3
+	// 触发类型 -- Cron表达式
+	TriggerTypeCron = "cron"
+	// 触发类型 -- 一次性，在指定时间点触发一次
+	TriggerTypeOnce = "once"
+	// 触发类型 -- 固定频率
+	TriggerTypeFixedRate = "fixed_rate"
+	// 触发类型 -- 固定延迟，上次执行完毕后间隔固定时长再触发
+	TriggerTypeFixedDelay = "fixed_delay"
 	// 故障转移 -- 启用
 	FailTakeoverEnabled = 1
 	// Http签名 -- 启用
 	HttpSignEnabled = 1
+	// 重试间隔抖动 -- 启用
+	RetryJitterEnabled = 1
 	// 执行节点状态 -- 可用
 	ExecutorStatusOk = 1
+	// HTTP请求方法 -- GET
+	HttpMethodGet = "GET"
+	// HTTP请求方法 -- POST
+	HttpMethodPost = "POST"
+	// HTTP请求方法 -- PUT
+	HttpMethodPut = "PUT"
+	// HTTP请求方法 -- DELETE
+	HttpMethodDelete = "DELETE"
+	// HTTP请求体类型 -- 默认
+	HttpContentTypeDefault = "application/json; charset=utf-8"
+	// 执行模式 -- 同步，HTTP响应即执行结果
+	ExecuteModeSync = "sync"
+	// 执行模式 -- 异步，执行节点确认后通过回调报告执行结果
+	ExecuteModeAsync = "async"
+	// 异步执行的默认完成期限（秒）
+	DefaultAsyncTimeout = 3600
+	// 并发策略 -- 允许同一作业的多次执行同时进行
+	ConcurrencyPolicyAllow = "allow"
+	// 并发策略 -- 禁止，上次执行未结束时跳过本次执行
+	ConcurrencyPolicyForbid = "forbid"
+	// 并发策略 -- 替换，取消未结束的执行后开始本次执行
+	ConcurrencyPolicyReplace = "replace"
+	// 错发策略 -- 只补偿执行一次
+	MisfirePolicyFireOnce = "once"
+	// 错发策略 -- 逐个补偿错过的触发时间点
+	MisfirePolicyFireAll = "all"
+	// 错发策略 -- 不补偿
+	MisfirePolicySkip = "skip"
+	// 逐个补偿时的默认最大补偿次数
+	DefaultMisfireMaxFires = 10
 )
 
 // 执行节点
 type Executor struct {
-	Address string `json:"address"` // 执行器地址
-	Weight  int    `json:"weight"`  // 权重
-	Status  int    `json:"status"`  // 状态 1上线 0下线
+	Address string            `json:"address"` // 执行器地址
+	Weight  int               `json:"weight"`  // 权重
+	Status  int               `json:"status"`  // 状态 1上线 0下线
+	Labels  map[string]string `json:"labels"`  // 标签，如zone=sh
 }
 
 // 作业
@@ -73,6 +149,12 @@ type Job struct {
 	IdStr                  string      `json:"id"`                     // 主键
 	Name                   string      `json:"name"`                   // 任务名称
 	Cron                   string      `json:"cron"`                   // cron 表达式
+	Timezone               string      `json:"timezone"`               // 时区，如Asia/Tokyo，为空时使用调度节点所在的时区
+	TriggerType            string      `json:"triggerType"`            // 触发类型 cron once fixed_rate fixed_delay，默认cron
+	TriggerTime            int64       `json:"triggerTime"`            // 一次性作业的触发时间，固定频率/延迟作业的首次触发时间（秒级时间戳）
+	Interval               int         `json:"interval"`               // 固定频率/延迟作业的触发间隔（秒）
+	EffectiveFrom          int64       `json:"effectiveFrom"`          // 有效期开始时间（秒级时间戳），0为不限制
+	EffectiveUntil         int64       `json:"effectiveUntil"`         // 有效期截止时间（秒级时间戳），0为不限制
 	Protocol               string      `json:"protocol"`               // 网络协议 http / https
 	Uri                    string      `json:"uri"`                    // 任务的资源标识符
 	Remark                 string      `json:"remark"`                 // 备注
@@ -83,20 +165,53 @@ type Job struct {
 	Timeout                int         `json:"timeout"`                // 任务超时时间
 	RetryCount             int         `json:"retryCount"`             // 重试次数
 	RetryWaitTime          int         `json:"retryWaitTime"`          // 重试间隔（秒）
+	RetryBackoff           string      `json:"retryBackoff"`           // 重试间隔策略 fixed固定 exponential指数退避，默认fixed
+	RetryMaxWaitTime       int         `json:"retryMaxWaitTime"`       // 最大重试间隔（秒），0为不限制
+	RetryJitter            int         `json:"retryJitter"`            // 重试间隔抖动 0不抖动 1在[0,重试间隔]内随机
+	RetryStatusCodes       string      `json:"retryStatusCodes"`       // 可重试的状态码，如429,5xx，为空时重试判定失败的响应，408、429以外的4xx除外
+	RetryErrorClasses      string      `json:"retryErrorClasses"`      // 可重试的网络错误类别 timeout refused reset dns eof other，为空时重试所有网络错误
+	ExecuteDeadline        int         `json:"executeDeadline"`        // 执行期限（秒），覆盖重试和故障转移，0为不限制
 	FailTakeover           int         `json:"failTakeover"`           // 故障转移 0不转移 1转移
 	MisfireThreshold       int64       `json:"misfireThreshold"`       // 触发器超时时间（秒）
+	MisfirePolicy          string      `json:"misfirePolicy"`          // 错发策略 once补偿一次 all逐个补偿 skip不补偿，默认once
+	MisfireMaxFires        int         `json:"misfireMaxFires"`        // 逐个补偿时的最大补偿次数
+	CalendarIds            []string    `json:"calendarIds"`            // 引用的日历ID
+	CalendarAction         string      `json:"calendarAction"`         // 触发时间点被日历排除时的策略 skip跳过 defer顺延，默认skip
 	ExecutorSelectStrategy string      `json:"executorSelectStrategy"` // 执行器选择策略 随机 全部 分片
+	ExecutorSelector       string      `json:"executorSelector"`       // 执行器标签选择器，如 zone in (sh,bj),gpu=false，为空时不过滤
+	HashKeyParam           string      `json:"hashKeyParam"`           // 一致性哈希键取值的http参数名，为空时使用作业ID
 	HttpParam              string      `json:"httpParam"`              // http参数
 	HttpHeaderParam        string      `json:"httpHeaderParam"`        // http头参数
 	HttpSign               int         `json:"httpSign"`               // http请求是否签名
+	HttpMethod             string      `json:"httpMethod"`             // http请求方法 GET POST PUT DELETE，默认GET
+	HttpContentType        string      `json:"httpContentType"`        // http请求体类型，默认application/json
+	HttpBody               string      `json:"httpBody"`               // http请求体模板
+	SuccessStatusCodes     string      `json:"successStatusCodes"`     // 判定成功的状态码，多个用逗号分隔，默认200
+	SuccessJsonPath        string      `json:"successJsonPath"`        // 判定成功的响应体JSON路径，如data.code
+	SuccessJsonValue       string      `json:"successJsonValue"`       // JSON路径的期望值
+	SuccessRegex           string      `json:"successRegex"`           // 判定成功的响应体正则表达式
+	ExecuteMode            string      `json:"executeMode"`            // 执行模式 sync同步 async异步，默认sync
+	AsyncTimeout           int         `json:"asyncTimeout"`           // 异步执行完成期限（秒）
+	ConcurrencyPolicy      string      `json:"concurrencyPolicy"`      // 并发策略 allow允许 forbid禁止 replace替换，默认allow
+	MaxConcurrency         int         `json:"maxConcurrency"`         // 同时执行的最大数量，0为不限制
+	StopUri                string      `json:"stopUri"`                // 取消执行时通知执行节点停止的资源标识符
+	HealthCheckUri         string      `json:"healthCheckUri"`         // 执行节点探活的资源标识符，为空时不主动探活
+	BroadcastSuccessRule   string      `json:"broadcastSuccessRule"`   // 广播成功规则 all全部成功 quorum过半成功 one至少一个成功，默认all
 	ShardingCount          int         `json:"shardingCount"`          // 分片总数
 	ShardingParam          string      `json:"shardingParam"`          // 分片参数
+	ShardingStrategy       string      `json:"shardingStrategy"`       // 分片策略 even平均 weight按权重 sticky粘性，默认even
+	ShardSource            string      `json:"shardSource"`            // 分片来源 static固定参数 http接口 date日期区间，默认static
+	ShardSourceUrl         string      `json:"shardSourceUrl"`         // 分片来源接口地址，返回JSON数组或者逗号、换行分隔的文本
+	ShardDateRange         string      `json:"shardDateRange"`         // 分片日期区间，相对调度日期的天数偏移，如 -7,-1
+	ShardDateFormat        string      `json:"shardDateFormat"`        // 分片日期格式，默认2006-01-02
 	AlarmEmail             string      `json:"alarmEmail"`             // 告警邮箱
 	SubJobScheduleStrategy int         `json:"subJobScheduleStrategy"` // 子JOB触发策略 0执行完毕触发 1执行成功触发 2执行失败触发
 	SubJobIds              []string    `json:"subJobIds"`              // 子JOB ID
 	SubJobDisplay          string      `json:"subJobDisplay"`          // 子JOB名称展示
 	TimeStep               int64       `json:"timeStep"`               // 时间步进
 	Executors              []*Executor `json:"executors"`              // 执行器
+	AppName                string      `json:"appName"`                // 应用名称，该应用下心跳正常的自注册实例参与执行
+	ExecutorGroupId        string      `json:"executorGroupId"`        // 引用的执行器组ID，执行器组中的执行器参与执行
 }
 
 // 作业VO
@@ -369,6 +484,130 @@ func GetJobAmount() int {
 	return amount
 }
 
+// 获取HTTP请求方法，未设置时为GET
+func (this *Job) GetHttpMethod() string {
+	if "" == this.HttpMethod {
+		return HttpMethodGet
+	}
+	return strings.ToUpper(this.HttpMethod)
+}
+
+// 获取HTTP请求体类型，未设置时为application/json
+func (this *Job) GetHttpContentType() string {
+	if "" == this.HttpContentType {
+		return HttpContentTypeDefault
+	}
+	return this.HttpContentType
+}
+
+// 是否为异步执行模式
+func (this *Job) IsAsync() bool {
+	return ExecuteModeAsync == this.ExecuteMode
+}
+
+// 获取异步执行完成期限（秒）
+func (this *Job) GetAsyncTimeout() int {
+	if this.AsyncTimeout <= 0 {
+		return DefaultAsyncTimeout
+	}
+	return this.AsyncTimeout
+}
+
+// 获取广播成功规则，未设置时为all
+func (this *Job) GetBroadcastSuccessRule() string {
+	if "" == this.BroadcastSuccessRule {
+		return BroadcastSuccessRuleAll
+	}
+	return this.BroadcastSuccessRule
+}
+
+// 获取一致性哈希键，取http参数中HashKeyParam对应的值，未设置或参数不存在时使用作业ID
+func (this *Job) GetHashKey() string {
+	if "" != this.HashKeyParam {
+		if value, exist := stringutil.KVsToMap(this.HttpParam, "|")[this.HashKeyParam]; exist {
+			return value
+		}
+	}
+	return stringutil.UintToStr(this.Id)
+}
+
+// 获取分片策略，未设置时为even
+func (this *Job) GetShardingStrategy() string {
+	if "" == this.ShardingStrategy {
+		return ShardingStrategyEven
+	}
+	return this.ShardingStrategy
+}
+
+// 获取重试间隔策略，未设置时为fixed
+func (this *Job) GetRetryBackoff() string {
+	if "" == this.RetryBackoff {
+		return RetryBackoffFixed
+	}
+	return this.RetryBackoff
+}
+
+// 获取分片来源，未设置时为static
+func (this *Job) GetShardSource() string {
+	if "" == this.ShardSource {
+		return ShardSourceStatic
+	}
+	return this.ShardSource
+}
+
+// 获取分片日期格式，未设置时为2006-01-02
+func (this *Job) GetShardDateFormat() string {
+	if "" == this.ShardDateFormat {
+		return dateutil.DayFormatter
+	}
+	return this.ShardDateFormat
+}
+
+// 获取日历策略，未设置时为skip
+func (this *Job) GetCalendarAction() string {
+	if "" == this.CalendarAction {
+		return CalendarActionSkip
+	}
+	return this.CalendarAction
+}
+
+// 获取触发类型，未设置时为cron
+func (this *Job) GetTriggerType() string {
+	if "" == this.TriggerType {
+		return TriggerTypeCron
+	}
+	return this.TriggerType
+}
+
+// 时间点是否已超过有效期截止时间，截止时间本身仍在有效期内，与调度计划在截止时间点仍会触发保持一致
+func (this *Job) IsExpiredAt(t int64) bool {
+	return this.EffectiveUntil > 0 && t > this.EffectiveUntil
+}
+
+// 获取并发策略，未设置时为allow
+func (this *Job) GetConcurrencyPolicy() string {
+	if "" == this.ConcurrencyPolicy {
+		return ConcurrencyPolicyAllow
+	}
+	return this.ConcurrencyPolicy
+}
+
+// 获取错发策略，未设置时为once
+func (this *Job) GetMisfirePolicy() string {
+	if "" == this.MisfirePolicy {
+		return MisfirePolicyFireOnce
+	}
+	return this.MisfirePolicy
+}
+
+// 获取逐个补偿时的最大补偿次数
+func (this *Job) GetMisfireMaxFires() int {
+	if this.MisfireMaxFires <= 0 {
+		return DefaultMisfireMaxFires
+	}
+	return this.MisfireMaxFires
+}
+
 func GetExecutorAmount() int {
 	amount := 0
 	GetBoltDB().View(func(tx *bolt.Tx) error {
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestIsExpiredAt(t *testing.T) {
+	job := &Job{EffectiveUntil: 1793955600}
+	if job.IsExpiredAt(1793955599) || job.IsExpiredAt(1793955600) {
+		t.Errorf("job should still be effective at the until time")
+	}
+	if !job.IsExpiredAt(1793955601) {
+		t.Errorf("job should be expired after the until time")
+	}
+	if (&Job{}).IsExpiredAt(1793955601) {
+		t.Errorf("job without until time should never expire")
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"gojob/util/stringutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// 标签选择器操作符 -- 等于
+	LabelOperatorEquals = "="
+	// 标签选择器操作符 -- 不等于，标签不存在时也匹配
+	LabelOperatorNotEquals = "!="
+	// 标签选择器操作符 -- 在取值列表中
+	LabelOperatorIn = "in"
+	// 标签选择器操作符 -- 不在取值列表中，标签不存在时也匹配
+	LabelOperatorNotIn = "notin"
+	// 标签选择器操作符 -- 存在标签
+	LabelOperatorExists = "exists"
+	// 标签选择器操作符 -- 不存在标签
+	LabelOperatorNotExists = "!"
+)
+
+var labelKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+var labelSetRegex = regexp.MustCompile(`^([A-Za-z0-9_./-]+)\s+(in|notin)\s*\((.*)\)$`)
+
+// 标签选择条件
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// 标签选择器，多个条件之间为"且"的关系；为空时匹配所有执行节点
+type LabelSelector []*LabelRequirement
+
+// 解析标签选择器表达式，条件之间用逗号分隔，支持：
+// key=value、key==value、key!=value、key in (v1,v2)、key notin (v1,v2)、key(存在)、!key(不存在)
+func ParseLabelSelector(expr string) (LabelSelector, error) {
+	selector := make(LabelSelector, 0)
+	for _, part := range splitRequirements(expr) {
+		part = strings.TrimSpace(part)
+		if "" == part {
+			continue
+		}
+		requirement, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, requirement)
+	}
+	return selector, nil
+}
+
+// 按括号外的逗号拆分条件
+func splitRequirements(expr string) []string {
+	parts := make([]string, 0)
+	depth := 0
+	start := 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if 0 == depth {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+func parseRequirement(part string) (*LabelRequirement, error) {
+	if matches := labelSetRegex.FindStringSubmatch(part); nil != matches {
+		values := make([]string, 0)
+		for _, value := range strings.Split(matches[3], ",") {
+			if value = strings.TrimSpace(value); "" != value {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return nil, errors.Errorf("标签选择条件的取值列表不能为空：%s", part)
+		}
+		return &LabelRequirement{Key: matches[1], Operator: matches[2], Values: values}, nil
+	}
+
+	requirement := new(LabelRequirement)
+	switch {
+	case strings.HasPrefix(part, "!") && !strings.Contains(part, "="):
+		requirement.Key = strings.TrimSpace(part[1:])
+		requirement.Operator = LabelOperatorNotExists
+	case strings.Contains(part, "!="):
+		kv := strings.SplitN(part, "!=", 2)
+		requirement.Key = strings.TrimSpace(kv[0])
+		requirement.Operator = LabelOperatorNotEquals
+		requirement.Values = []string{strings.TrimSpace(kv[1])}
+	case strings.Contains(part, "="):
+		kv := strings.SplitN(strings.Replace(part, "==", "=", 1), "=", 2)
+		requirement.Key = strings.TrimSpace(kv[0])
+		requirement.Operator = LabelOperatorEquals
+		requirement.Values = []string{strings.TrimSpace(kv[1])}
+	default:
+		requirement.Key = part
+		requirement.Operator = LabelOperatorExists
+	}
+	if !labelKeyRegex.MatchString(requirement.Key) {
+		return nil, errors.Errorf("标签选择条件格式错误：%s", part)
+	}
+	for _, value := range requirement.Values {
+		if strings.ContainsAny(value, "=!() ") {
+			return nil, errors.Errorf("标签选择条件格式错误：%s", part)
+		}
+	}
+	return requirement, nil
+}
+
+// 标签是否满足条件
+func (this *LabelRequirement) Matches(labels map[string]string) bool {
+	value, exist := labels[this.Key]
+	switch this.Operator {
+	case LabelOperatorEquals:
+		return exist && value == this.Values[0]
+	case LabelOperatorNotEquals:
+		return !exist || value != this.Values[0]
+	case LabelOperatorIn:
+		return exist && stringutil.InArray(value, this.Values)
+	case LabelOperatorNotIn:
+		return !exist || !stringutil.InArray(value, this.Values)
+	case LabelOperatorExists:
+		return exist
+	case LabelOperatorNotExists:
+		return !exist
+	}
+	return false
+}
+
+// 标签是否满足所有条件
+func (this LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range this {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"zone": "sh", "gpu": "false", "tier": "batch"}
+	cases := []struct {
+		expr    string
+		matched bool
+	}{
+		{"", true},
+		{"zone=sh", true},
+		{"zone==bj", false},
+		{"zone in (sh,bj)", true},
+		{"zone in (gz, bj)", false},
+		{"zone notin (gz,bj)", true},
+		{"tier!=online", true},
+		{"gpu=false, tier=batch", true},
+		{"zone in (sh,bj),gpu=true", false},
+		{"tier", true},
+		{"canary", false},
+		{"!canary", true},
+		{"!zone", false},
+		{"region notin (eu)", true}, // 标签不存在时notin匹配
+		{"region!=eu", true},
+	}
+	for _, c := range cases {
+		selector, err := ParseLabelSelector(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", c.expr, err.Error())
+		}
+		if matched := selector.Matches(labels); matched != c.matched {
+			t.Errorf("%s: expected %v, actual %v", c.expr, c.matched, matched)
+		}
+	}
+
+	for _, expr := range []string{"zone in ()", "zone in (sh", "=sh", "zone=s h", "zo ne=sh"} {
+		if _, err := ParseLabelSelector(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
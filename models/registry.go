@@ -0,0 +1,168 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"gojob/util/dateutil"
+
+	"github.com/boltdb/bolt"
+	"github.com/vmihailenco/msgpack"
+)
+
+// 执行节点心跳超时时间（秒），超过此时间未收到心跳的实例不再参与选择和分片；执行节点应每10秒左右发送一次心跳
+const ExecutorHeartbeatTimeout = 30
+
+// 自注册的执行节点实例
+type ExecutorInstance struct {
+	AppName       string            `json:"appName"`       // 应用名称，同一应用的实例组成一个执行器组
+	Address       string            `json:"address"`       // 执行节点地址 ip:port
+	Weight        int               `json:"weight"`        // 权重
+	Labels        map[string]string `json:"labels"`        // 标签
+	RegisterTime  int64             `json:"registerTime"`  // 注册时间（毫秒）
+	HeartbeatTime int64             `json:"heartbeatTime"` // 最近一次心跳时间（毫秒），持久化的为注册时间，心跳只在主节点内存中更新
+	Alive         bool              `json:"alive"`         // 心跳是否正常，查询时计算
+}
+
+type ExecutorInstanceSortableList []*ExecutorInstance
+
+func (ls ExecutorInstanceSortableList) Len() int {
+	return len(ls)
+}
+
+func (ls ExecutorInstanceSortableList) Less(i, j int) bool {
+	if ls[i].AppName == ls[j].AppName {
+		return ls[i].Address < ls[j].Address
+	}
+	return ls[i].AppName < ls[j].AppName
+}
+
+func (ls ExecutorInstanceSortableList) Swap(i, j int) {
+	ls[i], ls[j] = ls[j], ls[i]
+}
+
+func executorInstanceKey(appName string, address string) []byte {
+	return []byte(appName + "|" + address)
+}
+
+// 心跳是否在超时时间内
+func (this *ExecutorInstance) IsAlive(now int64) bool {
+	return now-this.HeartbeatTime <= ExecutorHeartbeatTimeout*1000
+}
+
+func SaveExecutorInstance(entity *ExecutorInstance) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(registryBucket)
+		bs, err := msgpack.Marshal(entity)
+		if err != nil {
+			return err
+		}
+		return bt.Put(executorInstanceKey(entity.AppName, entity.Address), bs)
+	})
+}
+
+func BatchSaveExecutorInstance(entities []*ExecutorInstance) error {
+	return GetBoltDB().Batch(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(registryBucket)
+		for _, entity := range entities {
+			bs, err := msgpack.Marshal(entity)
+			if err != nil {
+				continue
+			}
+			bt.Put(executorInstanceKey(entity.AppName, entity.Address), bs)
+		}
+		return nil
+	})
+}
+
+func DeleteExecutorInstance(appName string, address string) error {
+	return GetBoltDB().Update(func(tx *bolt.Tx) error {
+		bt := tx.Bucket(registryBucket)
+		return bt.Delete(executorInstanceKey(appName, address))
+	})
+}
+
+func GetExecutorInstance(appName string, address string) (*ExecutorInstance, bool) {
+	var entity *ExecutorInstance
+	GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(registryBucket)
+		val := bucket.Get(executorInstanceKey(appName, address))
+		if val != nil {
+			entity = new(ExecutorInstance)
+			if err := msgpack.Unmarshal(val, entity); err != nil {
+				entity = nil
+			}
+		}
+		return nil
+	})
+	return entity, nil != entity
+}
+
+func ForEachExecutorInstance() ([]*ExecutorInstance, error) {
+	now := dateutil.NowMillisecond()
+	list := make([]*ExecutorInstance, 0)
+	err := GetBoltDB().View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(registryBucket)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var entity = new(ExecutorInstance)
+			if err := msgpack.Unmarshal(v, entity); err == nil {
+				entity.Alive = entity.IsAlive(now)
+				list = append(list, entity)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// 按应用名称查询执行节点实例
+func SelectExecutorInstanceList(appName string) []*ExecutorInstance {
+	list, _ := ForEachExecutorInstance()
+	filtered := make([]*ExecutorInstance, 0, len(list))
+	for _, entity := range list {
+		if "" != appName && !strings.Contains(entity.AppName, appName) {
+			continue
+		}
+		filtered = append(filtered, entity)
+	}
+	sortables := ExecutorInstanceSortableList(filtered)
+	sort.Sort(sortables)
+	return sortables
+}
+
+// 获取应用下的执行节点实例
+func SelectAppExecutorInstances(appName string) []*ExecutorInstance {
+	list := make([]*ExecutorInstance, 0)
+	prefix := []byte(appName + "|")
+	now := dateutil.NowMillisecond()
+	GetBoltDB().View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(registryBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = cursor.Next() {
+			var entity = new(ExecutorInstance)
+			if err := msgpack.Unmarshal(v, entity); err == nil {
+				entity.Alive = entity.IsAlive(now)
+				list = append(list, entity)
+			}
+		}
+		return nil
+	})
+	return list
+}
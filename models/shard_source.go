@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 日期区间分片来源最多生成的分片数量
+const MaxDateShards = 1000
+
+// 日期区间，相对调度日期的天数偏移
+type ShardDateRange struct {
+	Start int // 起始偏移
+	End   int // 结束偏移（包含）
+}
+
+// 解析日期区间表达式，格式为 起始偏移,结束偏移，如 -7,-1 表示调度日期前7天到前1天；
+// 只有一个偏移时表示单日，如 -1 表示调度日期的前一天
+func ParseShardDateRange(expr string) (*ShardDateRange, error) {
+	parts := strings.Split(strings.TrimSpace(expr), ",")
+	if len(parts) > 2 {
+		return nil, errors.Errorf("日期区间格式错误：%s", expr)
+	}
+	offsets := make([]int, len(parts))
+	for i, part := range parts {
+		offset, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.Errorf("日期区间格式错误：%s", expr)
+		}
+		offsets[i] = offset
+	}
+	dateRange := &ShardDateRange{Start: offsets[0], End: offsets[len(offsets)-1]}
+	if dateRange.Start > dateRange.End {
+		return nil, errors.Errorf("日期区间起始偏移不能大于结束偏移：%s", expr)
+	}
+	if dateRange.End-dateRange.Start >= MaxDateShards {
+		return nil, errors.Errorf("日期区间超过%d天：%s", MaxDateShards, expr)
+	}
+	return dateRange, nil
+}
+
+// 以base所在的日期为基准，按layout格式生成区间内的每一天
+func (this *ShardDateRange) Generate(base time.Time, layout string) []string {
+	day := time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, base.Location())
+	list := make([]string, 0, this.End-this.Start+1)
+	for offset := this.Start; offset <= this.End; offset++ {
+		list = append(list, day.AddDate(0, 0, offset).Format(layout))
+	}
+	return list
+}
+
+// 解析分片来源接口的响应：JSON数组(元素为字符串或数字)，或者逗号、换行分隔的文本；忽略空白项
+func ParseShardList(body []byte) ([]string, error) {
+	text := strings.TrimSpace(string(body))
+	list := make([]string, 0)
+	if strings.HasPrefix(text, "[") {
+		var items []interface{}
+		if err := json.Unmarshal([]byte(text), &items); err != nil {
+			return nil, errors.Errorf("分片列表JSON格式错误：%s", err.Error())
+		}
+		for _, item := range items {
+			switch v := item.(type) {
+			case string:
+				if "" != strings.TrimSpace(v) {
+					list = append(list, strings.TrimSpace(v))
+				}
+			case float64:
+				list = append(list, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				return nil, errors.Errorf("不支持的分片参数：%v", item)
+			}
+		}
+	} else {
+		for _, item := range strings.FieldsFunc(text, func(r rune) bool {
+			return ',' == r || '\n' == r || '\r' == r
+		}) {
+			if "" != strings.TrimSpace(item) {
+				list = append(list, strings.TrimSpace(item))
+			}
+		}
+	}
+	for _, item := range list {
+		if strings.Contains(item, ",") {
+			return nil, errors.Errorf("分片参数不能包含逗号：%s", item)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShardDateRange(t *testing.T) {
+	base := time.Date(2021, 3, 2, 1, 30, 0, 0, time.UTC)
+	cases := []struct {
+		expr     string
+		layout   string
+		expected string
+	}{
+		{"-3,-1", "2006-01-02", "2021-02-27,2021-02-28,2021-03-01"},
+		{"0", "20060102", "20210302"},
+		{" -1 , 1 ", "01/02", "03/01,03/02,03/03"},
+	}
+	for _, c := range cases {
+		dateRange, err := ParseShardDateRange(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", c.expr, err.Error())
+		}
+		if actual := strings.Join(dateRange.Generate(base, c.layout), ","); actual != c.expected {
+			t.Errorf("%s: expected %s, actual %s", c.expr, c.expected, actual)
+		}
+	}
+
+	for _, expr := range []string{"", "a,b", "-1,-3", "1,2,3", "0,1000"} {
+		if _, err := ParseShardDateRange(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
+
+func TestParseShardList(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{`["t1", "t2", " ", 3, 4.5]`, "t1,t2,3,4.5"},
+		{"p0,p1\np2\r\n\n", "p0,p1,p2"},
+		{"  ", ""},
+	}
+	for _, c := range cases {
+		list, err := ParseShardList([]byte(c.body))
+		if err != nil {
+			t.Fatalf("%q: %s", c.body, err.Error())
+		}
+		if actual := strings.Join(list, ","); actual != c.expected {
+			t.Errorf("%q: expected %s, actual %s", c.body, c.expected, actual)
+		}
+	}
+
+	for _, body := range []string{`["a,b"]`, `[{"id":1}]`, `[1,`} {
+		if _, err := ParseShardList([]byte(body)); err == nil {
+			t.Errorf("%q: expected error", body)
+		}
+	}
+}
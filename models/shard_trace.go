@@ -0,0 +1,158 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package models
+
+import (
+	"strconv"
+
+	"gojob/util/logs"
+
+	"github.com/go-xorm/xorm"
+)
+
+const (
+	createShardTraceTableSql = "CREATE TABLE `t_shard_trace`  (" +
+		"`ID` bigint(18) NOT NULL COMMENT '主键'," +
+		"`TRACE_ID` bigint(18) NULL DEFAULT NULL COMMENT '调度跟踪主键'," +
+		"`JOB_ID` bigint(18) NULL DEFAULT NULL COMMENT 'JOB主键'," +
+		"`SHARD_PARAM` varchar(1000) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '分片参数'," +
+		"`EXECUTOR` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '最后执行的执行节点'," +
+		"`ATTEMPTS` int(4) NULL DEFAULT NULL COMMENT '执行次数，包含故障转移'," +
+		"`EXECUTE_STATUS` int(2) NULL DEFAULT NULL COMMENT '执行状态 0失败/1成功/2执行中'," +
+		"`START_TIME` bigint(10) NULL DEFAULT NULL COMMENT '开始时间（毫秒）'," +
+		"`DURATION` bigint(10) NULL DEFAULT NULL COMMENT '耗时（毫秒）'," +
+		"PRIMARY KEY (`ID`) USING BTREE," +
+		"INDEX `index_trace_id`(`TRACE_ID`) USING BTREE," +
+		"INDEX `index_job_id`(`JOB_ID`) USING BTREE" +
+		") "
+)
+
+// 分片执行记录，每个分发到执行节点的分片参数一条，关联调度跟踪信息
+type ShardTrace struct {
+	Id            uint64 `xorm:"pk" json:"-"`   // 主键
+	IdStr         string `xorm:"-" json:"id"`   // 主键
+	TraceId       uint64 `json:"-"`             // 调度跟踪主键
+	JobId         uint64 `json:"-"`             // JOB主键
+	ShardParam    string `json:"shardParam"`    // 分片参数
+	Executor      string `json:"executor"`      // 最后执行的执行节点
+	Attempts      int    `json:"attempts"`      // 执行次数，包含故障转移
+	ExecuteStatus int    `json:"executeStatus"` // 执行状态
+	StartTime     int64  `json:"startTime"`     // 开始时间（毫秒）
+	Duration      int64  `json:"duration"`      // 耗时（毫秒）
+}
+
+func createShardTraceTableNecessary(engine *xorm.Engine) error {
+	exist, err := engine.IsTableExist("t_shard_trace")
+	if err != nil {
+		return err
+	}
+	if !exist {
+		_, err := engine.Exec(createShardTraceTableSql)
+		return err
+	}
+	return nil
+}
+
+// 保存分片执行记录，多数据库时同步写入所有可用的数据库
+func InsertShardTraces(list []*ShardTrace) error {
+	if len(list) == 0 {
+		return nil
+	}
+	_, err := GetOrm().Insert(&list)
+
+	if isRedundancy() {
+		if err != nil {
+			if tryCutDB() {
+				_, err = GetOrm().Insert(&list)
+			}
+		}
+		current := getCurrentDB().name
+		redundancyMap.Range(func(key, value interface{}) bool {
+			if key.(string) != current && !isDBInvalid(key.(string)) {
+				r := value.(*redundancy)
+				if _, err := r.engine.Insert(&list); err != nil {
+					logs.Errorf(err.Error())
+				}
+			}
+			return true
+		})
+	}
+
+	return err
+}
+
+var shardTraceResultColumns = []string{"EXECUTE_STATUS", "DURATION"}
+
+// 更新分片执行结果，异步执行的分片在收到执行节点回调后更新
+func UpdateShardTraceResults(list []*ShardTrace) error {
+	var err error
+	for _, entity := range list {
+		if _, e := GetOrm().ID(entity.Id).Cols(shardTraceResultColumns...).Update(entity); nil != e {
+			err = e
+		}
+	}
+
+	if isRedundancy() {
+		if err != nil {
+			if tryCutDB() {
+				err = nil
+				for _, entity := range list {
+					if _, e := GetOrm().ID(entity.Id).Cols(shardTraceResultColumns...).Update(entity); nil != e {
+						err = e
+					}
+				}
+			}
+		}
+		current := getCurrentDB().name
+		redundancyMap.Range(func(key, value interface{}) bool {
+			if key.(string) != current && !isDBInvalid(key.(string)) {
+				r := value.(*redundancy)
+				for _, entity := range list {
+					if _, err := r.engine.ID(entity.Id).Cols(shardTraceResultColumns...).Update(entity); err != nil {
+						logs.Errorf(err.Error())
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	return err
+}
+
+// 查询调度的分片执行记录
+func SelectShardTraces(traceId uint64) ([]*ShardTrace, error) {
+	list := make([]*ShardTrace, 0)
+	err := GetOrm().Where("TRACE_ID=?", traceId).Asc("ID").Find(&list)
+	for _, entity := range list {
+		entity.IdStr = strconv.FormatUint(entity.Id, 10)
+	}
+	return list, err
+}
+
+// 清理调度跟踪信息时一并清理分片执行记录
+func cleanShardTraceSql(jobId uint64, timestamp int64) string {
+	sql := "DELETE FROM T_SHARD_TRACE WHERE 1=1"
+	if 0 != jobId {
+		sql = sql + " AND JOB_ID = " + strconv.FormatUint(jobId, 10)
+	}
+	if 0 != timestamp {
+		sql = sql + " AND START_TIME < " + strconv.FormatInt(timestamp*1000, 10)
+	}
+	return sql
+}
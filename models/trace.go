@@ -38,10 +38,18 @@ const (
 	ScheduleTypeCompensation = 2
 	// 调度类型 -- 依赖
 	ScheduleTypeDepend = 3
+	// 调度类型 -- 重跑失败分片
+	ScheduleTypeRerunShards = 4
 	// 执行状态 -- 失败
 	ExecuteStatusFailed = 0
 	// 执行状态 -- 成功
 	ExecuteStatusSucceed = 1
+	// 执行状态 -- 执行中，等待执行节点回调
+	ExecuteStatusRunning = 2
+	// 执行状态 -- 跳过，受并发策略限制未执行
+	ExecuteStatusSkipped = 3
+	// 执行状态 -- 已取消
+	ExecuteStatusCancelled = 4
 	// 日志数据清理范围 -- 全部
 	cleanScopeAll = "1"
 	// 日志数据清理范围 -- 一周前
@@ -57,6 +65,7 @@ const (
 	// 日志数据清理范围 -- 六月前
 	cleanScopeYearAgo     = "7"
 	selectMaxStartTimeSql = "SELECT MAX(START_TIME) FROM T_TRACE"
+	existTraceColumnSql   = "SELECT COUNT(1) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 't_trace' AND COLUMN_NAME = ?"
 	statisticTraceSql     = "SELECT T.JOB_ID," +
 		"COUNT(1) AS TOTAL," +
 		"SUM(CASE WHEN T.EXECUTE_STATUS = 1 THEN 1  ELSE 0  END) SUCCEED," +
@@ -83,9 +92,10 @@ const (
 		"`SCHEDULE_TYPE` int(2) NULL DEFAULT NULL COMMENT '调度类型 0手动/1自动/2补偿'," +
 		"`START_TIME` bigint(10) NULL DEFAULT NULL COMMENT '开始时间'," +
 		"`END_TIME` bigint(10) NULL DEFAULT NULL COMMENT '结束时间'," +
-		"`EXECUTE_STATUS` int(2) NULL DEFAULT NULL COMMENT '执行状态 0失败/1成功'," +
+		"`EXECUTE_STATUS` int(2) NULL DEFAULT NULL COMMENT '执行状态 0失败/1成功/2执行中/3跳过/4取消'," +
 		"`EXECUTE_RESULT` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '调度信息'," +
 		"`EXECUTE_DETAIL` varchar(1000) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '执行明细'," +
+		"`RESPONSE_BODY` varchar(1000) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '响应内容'," +
 		"PRIMARY KEY (`ID`) USING BTREE," +
 		"INDEX `index_job_id`(`JOB_ID`) USING BTREE," +
 		"INDEX `index_start_time`(`START_TIME`) USING BTREE" +
@@ -104,6 +114,7 @@ type Trace struct {
 	ExecuteStatus int    `json:"executeStatus"` // 执行状态
 	ExecuteResult string `json:"executeResult"` // 执行结果
 	ExecuteDetail string `json:"executeDetail"` // 调度明细信息
+	ResponseBody  string `json:"responseBody"`  // 响应内容（截断）
 }
 
 // 调度跟踪统计
@@ -118,6 +129,14 @@ type TraceStatistic struct {
 
 var traceSyncQueue = make(chan *Trace, 65535)
 
+// 执行结果相关的列
+var traceResultColumns = []string{"end_time", "execute_status", "execute_result", "execute_detail", "response_body"}
+
+// 旧版本表结构中缺少的列：列名、列定义
+var traceUpgradeColumns = [][2]string{
+	{"RESPONSE_BODY", "varchar(1000) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '响应内容'"},
+}
+
 func createTraceTableNecessary(engine *xorm.Engine) error {
 	exist, err := engine.IsTableExist("t_trace")
 	if err != nil {
@@ -129,6 +148,22 @@ func createTraceTableNecessary(engine *xorm.Engine) error {
 			return err
 		}
 	}
+	return addTraceColumnsNecessary(engine)
+}
+
+// 升级旧版本的表结构
+func addTraceColumnsNecessary(engine *xorm.Engine) error {
+	for _, column := range traceUpgradeColumns {
+		var count int
+		if err := engine.DB().QueryRow(existTraceColumnSql, column[0]).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			if _, err := engine.Exec("ALTER TABLE `t_trace` ADD COLUMN `" + column[0] + "` " + column[1]); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -153,6 +188,38 @@ func InsertTrace(trace *Trace) error {
 	return err
 }
 
+// 更新执行结果，用于异步执行完成时
+func UpdateTraceResult(trace *Trace) error {
+	_, err := GetOrm().ID(trace.Id).Cols(traceResultColumns...).Update(trace)
+
+	if isRedundancy() {
+		if err != nil {
+			if tryCutDB() {
+				_, err = GetOrm().ID(trace.Id).Cols(traceResultColumns...).Update(trace)
+			}
+		}
+		current := getCurrentDB().name
+		redundancyMap.Range(func(key, value interface{}) bool {
+			if key.(string) != current && !isDBInvalid(key.(string)) {
+				r := value.(*redundancy)
+				if _, err := r.engine.ID(trace.Id).Cols(traceResultColumns...).Update(trace); err != nil {
+					logs.Errorf(err.Error())
+				}
+			}
+			return true
+		})
+	}
+
+	return err
+}
+
+// 查询执行中的调度跟踪信息
+func SelectRunningTraces() ([]*Trace, error) {
+	list := make([]*Trace, 0)
+	err := GetOrm().Where("EXECUTE_STATUS=?", ExecuteStatusRunning).Find(&list)
+	return list, err
+}
+
 func SelectTracePage(page *Page) error {
 	jobName := page.GetStringParam("jobName")
 	startTime := page.GetStringParam("startTime")
@@ -220,11 +287,16 @@ func CleanTrace(jobId uint64, scope string) {
 		sql = sql + "AND START_TIME < " + strconv.FormatInt(timestamp, 10)
 	}
 
+	shardSql := cleanShardTraceSql(jobId, timestamp)
+
 	redundancyMap.Range(func(key, value interface{}) bool {
 		if !isDBInvalid(key.(string)) {
 			if _, err := value.(*redundancy).engine.Exec(sql); err != nil {
 				logs.Errorf(err.Error())
 			}
+			if _, err := value.(*redundancy).engine.Exec(shardSql); err != nil {
+				logs.Errorf(err.Error())
+			}
 		}
 		return true
 	})
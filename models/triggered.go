@@ -18,8 +18,6 @@
 package models
 
 import (
-	"time"
-
 	"gojob/util/byteutil"
 	"gojob/util/stringutil"
 
@@ -118,38 +116,3 @@ func ForEachTriggered() ([]*Triggered, error) {
 	})
 	return list, err
 }
-
-func SelectMisfireList() []*Triggered {
-	current := time.Now().Unix()
-	list := make([]*Triggered, 0)
-	GetBoltDB().View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(triggeredBucket)
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			entity := new(Triggered)
-			if err := msgpack.Unmarshal(v, entity); err == nil {
-				if entity.PrevTime >= entity.NextTime {
-					continue
-				}
-				job, err := GetJob(entity.Id)
-				if nil != err {
-					continue
-				}
-				if job.Status == JobStatusPause {
-					continue
-				}
-				if job.MisfireThreshold == 0 {
-					continue
-				}
-				if current > entity.NextTime {
-					diff := current - entity.NextTime
-					if diff <= job.MisfireThreshold {
-						list = append(list, entity)
-					}
-				}
-			}
-		}
-		return nil
-	})
-	return list
-}
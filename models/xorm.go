@@ -69,6 +69,9 @@ func InitXorm(configs []*DataSourceConfig) {
 		ds.name = config.DataSourceName
 		ds.mixName = getMixDataSourceName(config.DataSourceName)
 		err := createTraceTableNecessary(ds.engine)
+		if nil == err {
+			err = createShardTraceTableNecessary(ds.engine)
+		}
 		if err != nil {
 			logs.Errorf("创建表失败，您可以使用数据库初始化SQL自行建表: %s \n", err.Error())
 			log.Panicf("创建表失败，您可以使用数据库初始化SQL自行建表: %s \n", err.Error())
@@ -80,6 +80,9 @@ func initActions() {
 	bolt.GET("/user", forEachUser)
 	bolt.GET("/node", forEachNode)
 	bolt.GET("/alarm_config", forEachAlarmConfig)
+	bolt.GET("/calendar", forEachCalendar)
+	bolt.GET("/registry", forEachExecutorInstance)
+	bolt.GET("/executor_group", forEachExecutorGroup)
 	bolt.GET("/snapshot_version", forEachSnapshotVersion)
 	bolt.GET("/raft_flag", forEachRaftFlag)
 
@@ -87,6 +90,14 @@ func initActions() {
 	//cluster.Use(signMiddleware())
 	cluster.GET("/join/:peer_node_name/:peer_http_addr/:peer_tcp_addr", joinCluster)
 	cluster.GET("/leader_id", getClusterLeaderId)
+	cluster.POST("/executions/:id/callback", signMiddleware(), executionCallback)
+
+	api := router.Group("/api")
+	api.Use(signMiddleware())
+	api.POST("/jobs", createJob)
+	api.POST("/executors/register", registerExecutor)
+	api.POST("/executors/heartbeat", heartbeatExecutor)
+	api.POST("/executors/unregister", unregisterExecutor)
 
 	ui := router.Group("/ui")
 	ui.Use(authMiddleware())
@@ -95,12 +106,15 @@ func initActions() {
 	})
 	ui.POST("jobs", insertJob)
 	ui.POST("jobs/cron_validate", validateCron)
+	ui.GET("jobs/cron_preview", previewCron)
 	ui.DELETE("jobs/:id", deleteJob)
 	ui.PUT("jobs", updateJob)
 	ui.PUT("jobs/update_status/:id/:status", updateJobStatus)
 	ui.GET("jobs", searchJob)
 	ui.GET("jobs/:id", getJob)
 	ui.GET("jobs/:id/launch", launchJob)
+	ui.GET("executions", listExecutions)
+	ui.POST("executions/:id/cancel", cancelExecution)
 
 	ui.GET("users", searchUser)
 	ui.GET("users/name/:name", getUser)
@@ -111,6 +125,20 @@ func initActions() {
 	ui.GET("users/logout", logout)
 	ui.GET("users/authorised", authorised)
 
+	ui.GET("calendars", searchCalendar)
+	ui.GET("calendars/:id", getCalendar)
+	ui.POST("calendars", insertCalendar)
+	ui.PUT("calendars", updateCalendar)
+	ui.DELETE("calendars/:id", deleteCalendar)
+
+	ui.GET("executor_instances", searchExecutorInstance)
+
+	ui.GET("executor_groups", searchExecutorGroup)
+	ui.GET("executor_groups/:id", getExecutorGroup)
+	ui.POST("executor_groups", insertExecutorGroup)
+	ui.PUT("executor_groups", updateExecutorGroup)
+	ui.DELETE("executor_groups/:id", deleteExecutorGroup)
+
 	ui.GET("alarm_configs", getAlarmConfig)
 	ui.PUT("alarm_configs", updateAlarmConfig)
 	ui.POST("alarm_configs/test", testAlarmConfig)
@@ -124,6 +152,8 @@ func initActions() {
 
 	ui.GET("traces", tracePage)
 	ui.GET("traces/:id", getTrace)
+	ui.GET("traces/:id/shards", getShardTraces)
+	ui.POST("traces/:id/rerun_failed_shards", rerunFailedShards)
 	ui.POST("traces/clean", cleanTrace)
 	ui.GET("statistic/today", statisticTodayTrace)
 	ui.GET("statistic/week", statisticWeekTrace)
@@ -74,6 +74,36 @@ func forEachAlarmConfig(c *gin.Context) {
 	}
 }
 
+func forEachCalendar(c *gin.Context) {
+	datas, err := models.ForEachCalendar()
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+	} else {
+		respondData(c, datas)
+	}
+}
+
+func forEachExecutorInstance(c *gin.Context) {
+	datas, err := models.ForEachExecutorInstance()
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+	} else {
+		respondData(c, datas)
+	}
+}
+
+func forEachExecutorGroup(c *gin.Context) {
+	datas, err := models.ForEachExecutorGroup()
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+	} else {
+		respondData(c, datas)
+	}
+}
+
 func forEachSnapshotVersion(c *gin.Context) {
 	v := models.GetSnapshotVersion()
 	respondData(c, v)
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package routes
+
+import (
+	"time"
+
+	"gojob/internal"
+	"gojob/internal/icron"
+	"gojob/models"
+	"gojob/util/dateutil"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 校验日历属性
+func checkCalendar(calendar *models.Calendar) error {
+	if "" == calendar.Name {
+		return errors.Errorf("日历名称不能为空")
+	}
+	if err := icron.ValidateTimezone(calendar.Timezone); err != nil {
+		return errors.Errorf("时区错误：%s", err.Error())
+	}
+	for _, dates := range [][]string{calendar.Holidays, calendar.Workdays} {
+		for _, date := range dates {
+			if _, err := time.Parse(dateutil.DayFormatter, date); err != nil {
+				return errors.Errorf("日期格式错误：%s，应为yyyy-MM-dd", date)
+			}
+		}
+	}
+	for _, blackout := range calendar.Blackouts {
+		if nil == blackout {
+			return errors.Errorf("封锁时段不能为空")
+		}
+		start, err := models.ParseClock(blackout.Start)
+		if err != nil {
+			return err
+		}
+		end, err := models.ParseClock(blackout.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return errors.Errorf("封锁时段的开始时间与结束时间不能相同")
+		}
+		for _, weekday := range blackout.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return errors.Errorf("星期取值错误：%d，应为0(周日)~6(周六)", weekday)
+			}
+		}
+	}
+	return nil
+}
+
+func insertCalendar(c *gin.Context) {
+	calendar := new(models.Calendar)
+	err := c.BindJSON(calendar)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err = checkCalendar(calendar); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.InsertCalendar(calendar)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func updateCalendar(c *gin.Context) {
+	calendar := new(models.Calendar)
+	err := c.BindJSON(calendar)
+	if nil != err {
+		logs.Error(err.Error())
+		respond400(c, err.Error())
+		return
+	}
+	calendar.Id = stringutil.ToUintSafe(calendar.IdStr)
+	if _, err = models.GetCalendar(calendar.Id); nil != err {
+		respond400(c, "日历不存在")
+		return
+	}
+	if err = checkCalendar(calendar); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.SaveCalendar(calendar)
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func deleteCalendar(c *gin.Context) {
+	id := c.Param("id")
+	jobs, _ := models.ForEachJob()
+	for _, job := range jobs {
+		if stringutil.InArray(id, job.CalendarIds) {
+			respond400(c, "日历正在被任务("+job.Name+")使用，不能删除")
+			return
+		}
+	}
+
+	err := internal.DeleteCalendar(stringutil.ToUintSafe(id))
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func getCalendar(c *gin.Context) {
+	id := stringutil.ToUintSafe(c.Param("id"))
+	calendar, err := models.GetCalendar(id)
+	if nil != err {
+		respond500(c, err.Error())
+	} else {
+		respondData(c, calendar)
+	}
+}
+
+func searchCalendar(c *gin.Context) {
+	list := models.SelectCalendarList(c.Query("name"))
+	if "" == c.Query("page_num") || "" == c.Query("page_size") {
+		respondData(c, list)
+		return
+	}
+
+	pageSize := stringutil.ToIntSafe(c.Query("page_size"))
+	startIndex := (stringutil.ToIntSafe(c.Query("page_num")) - 1) * pageSize
+	slice := make([]*models.Calendar, 0)
+	for i := 0; i < pageSize; i++ {
+		index := startIndex + i
+		if index >= 0 && index < len(list) {
+			slice = append(slice, list[index])
+		}
+	}
+	respondPage(c, &models.Page{
+		Total: int64(len(list)),
+		Data:  slice,
+	})
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package routes
+
+import (
+	"net/http"
+
+	"gojob/internal"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 执行节点回调异步执行结果
+func executionCallback(c *gin.Context) {
+	id := stringutil.ToUintSafe(c.Param("id"))
+	callback := new(internal.AsyncCallback)
+	err := c.BindJSON(callback)
+	if nil != err {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if "" == callback.Address {
+		callback.Address = c.ClientIP()
+	}
+	err = internal.CompleteAsyncExecution(id, callback)
+	if nil != err {
+		logs.Warn(err.Error())
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "succeed")
+}
+
+// 执行中的调度列表
+func listExecutions(c *gin.Context) {
+	jobId := stringutil.ToUintSafe(c.Query("job_id"))
+	respondData(c, internal.GetActiveExecutions(jobId))
+}
+
+// 取消执行中的调度
+func cancelExecution(c *gin.Context) {
+	id := stringutil.ToUintSafe(c.Param("id"))
+	reason := "手动取消"
+	if cf, err := doAuthorised(c.Request.Header.Get("Authorization")); err == nil {
+		reason = "用户" + cf.User.Name + "手动取消"
+	}
+	err := internal.CancelExecution(id, reason)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
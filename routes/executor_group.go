@@ -0,0 +1,152 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package routes
+
+import (
+	"strings"
+
+	"gojob/internal"
+	"gojob/models"
+	"gojob/util/logs"
+	"gojob/util/stringutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 校验执行器组属性
+func checkExecutorGroup(group *models.ExecutorGroup) error {
+	if "" == group.Name {
+		return errors.Errorf("执行器组名称不能为空")
+	}
+	if strings.Contains(group.AppName, "|") {
+		return errors.Errorf("应用名称不能包含字符'|'")
+	}
+	if group.MaxConcurrency < 0 || group.RateLimit < 0 {
+		return errors.Errorf("并发限制和每秒请求数限制不能小于0")
+	}
+	if len(group.Executors) == 0 && "" == group.AppName {
+		return errors.Errorf("执行器和应用名称不能同时为空")
+	}
+	for _, executor := range group.Executors {
+		if nil == executor || "" == executor.Address {
+			return errors.Errorf("执行器地址不能为空")
+		}
+		if executor.Weight < 0 {
+			return errors.Errorf("权重不能小于0")
+		}
+	}
+	return nil
+}
+
+func insertExecutorGroup(c *gin.Context) {
+	group := new(models.ExecutorGroup)
+	err := c.BindJSON(group)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err = checkExecutorGroup(group); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.InsertExecutorGroup(group)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func updateExecutorGroup(c *gin.Context) {
+	group := new(models.ExecutorGroup)
+	err := c.BindJSON(group)
+	if nil != err {
+		logs.Error(err.Error())
+		respond400(c, err.Error())
+		return
+	}
+	group.Id = stringutil.ToUintSafe(group.IdStr)
+	if _, err = models.GetExecutorGroup(group.Id); nil != err {
+		respond400(c, "执行器组不存在")
+		return
+	}
+	if err = checkExecutorGroup(group); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.SaveExecutorGroup(group)
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func deleteExecutorGroup(c *gin.Context) {
+	id := c.Param("id")
+	jobs, _ := models.ForEachJob()
+	for _, job := range jobs {
+		if id == job.ExecutorGroupId {
+			respond400(c, "执行器组正在被任务("+job.Name+")使用，不能删除")
+			return
+		}
+	}
+
+	err := internal.DeleteExecutorGroup(stringutil.ToUintSafe(id))
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func getExecutorGroup(c *gin.Context) {
+	id := stringutil.ToUintSafe(c.Param("id"))
+	group, err := models.GetExecutorGroup(id)
+	if nil != err {
+		respond500(c, err.Error())
+	} else {
+		respondData(c, group)
+	}
+}
+
+func searchExecutorGroup(c *gin.Context) {
+	list := models.SelectExecutorGroupList(c.Query("name"))
+	if "" == c.Query("page_num") || "" == c.Query("page_size") {
+		respondData(c, list)
+		return
+	}
+
+	pageSize := stringutil.ToIntSafe(c.Query("page_size"))
+	startIndex := (stringutil.ToIntSafe(c.Query("page_num")) - 1) * pageSize
+	slice := make([]*models.ExecutorGroup, 0)
+	for i := 0; i < pageSize; i++ {
+		index := startIndex + i
+		if index >= 0 && index < len(list) {
+			slice = append(slice, list[index])
+		}
+	}
+	respondPage(c, &models.Page{
+		Total: int64(len(list)),
+		Data:  slice,
+	})
+}
@@ -19,22 +19,32 @@ package routes
 
 import (
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"gojob/internal"
 	"gojob/internal/icron"
 	"gojob/models"
+	"gojob/util/httputil"
 	"gojob/util/logs"
 	"gojob/util/stringutil"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 )
 
 const (
 	searchTypeJobList         = "1"
 	searchTypeSubJobSelection = "2"
+	apiJobCreator             = "api"
 )
 
+// 可重试状态码，如503或者5xx
+var retryStatusCodePattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
 func insertJob(c *gin.Context) {
 	job := new(models.Job)
 	err := c.BindJSON(job)
@@ -43,6 +53,11 @@ func insertJob(c *gin.Context) {
 		return
 	}
 
+	if err = checkJob(job); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
 	err = internal.InsertJob(job)
 	if nil != err {
 		respond500(c, err.Error())
@@ -52,6 +67,45 @@ func insertJob(c *gin.Context) {
 	respondOK(c)
 }
 
+// 其它系统创建作业的请求，delay为距当前时间的延迟（秒），设置时覆盖triggerTime
+type createJobRequest struct {
+	models.Job
+	Delay int `json:"delay"`
+}
+
+// 供其它系统调用的创建作业接口，返回作业ID
+func createJob(c *gin.Context) {
+	request := new(createJobRequest)
+	err := c.BindJSON(request)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	job := &request.Job
+	if request.Delay > 0 {
+		job.TriggerTime = time.Now().Unix() + int64(request.Delay)
+		if "" == job.TriggerType {
+			job.TriggerType = models.TriggerTypeOnce
+		}
+	}
+	if "" == job.Creator {
+		job.Creator = apiJobCreator
+	}
+	if err = checkJob(job); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.InsertJob(job)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+
+	respondData(c, stringutil.UintToStr(job.Id))
+}
+
 func deleteJob(c *gin.Context) {
 	id := stringutil.ToUintSafe(c.Param("id"))
 	err := internal.DeleteJob(id)
@@ -73,6 +127,10 @@ func updateJob(c *gin.Context) {
 
 	job.Id = stringutil.ToUintSafe(job.IdStr)
 	job.SubJobDisplay = ""
+	if err = checkJob(job); nil != err {
+		respond400(c, err.Error())
+		return
+	}
 	err = internal.UpdateJob(job)
 	if nil != err {
 		logs.Error(err.Error())
@@ -83,6 +141,153 @@ func updateJob(c *gin.Context) {
 	respondOK(c)
 }
 
+// 校验作业属性
+func checkJob(job *models.Job) error {
+	if err := icron.ValidateTimezone(job.Timezone); err != nil {
+		return errors.Errorf("时区错误：%s", err.Error())
+	}
+	switch job.GetTriggerType() {
+	case models.TriggerTypeCron:
+		if err := icron.ValidateCronSpec(job.Cron); err != nil {
+			return errors.Errorf("Cron表达式错误：%s", err.Error())
+		}
+	case models.TriggerTypeOnce:
+		if job.TriggerTime <= 0 {
+			return errors.Errorf("一次性作业的触发时间不能为空")
+		}
+	case models.TriggerTypeFixedRate, models.TriggerTypeFixedDelay:
+		if job.Interval <= 0 {
+			return errors.Errorf("触发间隔必须大于0")
+		}
+	default:
+		return errors.Errorf("不支持的触发类型：%s", job.TriggerType)
+	}
+	switch job.GetHttpMethod() {
+	case models.HttpMethodGet, models.HttpMethodPost, models.HttpMethodPut, models.HttpMethodDelete:
+	default:
+		return errors.Errorf("不支持的HTTP请求方法：%s", job.HttpMethod)
+	}
+	if "" != job.HttpBody {
+		if _, err := template.New("body").Parse(job.HttpBody); err != nil {
+			return errors.Errorf("请求体模板错误：%s", err.Error())
+		}
+	}
+	if "" != job.SuccessStatusCodes {
+		for _, code := range strings.Split(job.SuccessStatusCodes, ",") {
+			if _, err := strconv.Atoi(strings.TrimSpace(code)); err != nil {
+				return errors.Errorf("成功状态码格式错误：%s", job.SuccessStatusCodes)
+			}
+		}
+	}
+	switch job.GetRetryBackoff() {
+	case models.RetryBackoffFixed, models.RetryBackoffExponential:
+	default:
+		return errors.Errorf("不支持的重试间隔策略：%s", job.RetryBackoff)
+	}
+	policy := internal.GetRetryPolicy(job)
+	for _, code := range policy.StatusCodes {
+		if !retryStatusCodePattern.MatchString(strings.ToLower(code)) {
+			return errors.Errorf("可重试状态码格式错误：%s", code)
+		}
+	}
+	for _, class := range policy.ErrorClasses {
+		if !stringutil.InArray(class, httputil.ErrorClasses) {
+			return errors.Errorf("不支持的网络错误类别：%s", class)
+		}
+	}
+	if job.RetryMaxWaitTime < 0 || job.ExecuteDeadline < 0 {
+		return errors.Errorf("最大重试间隔和执行期限不能小于0")
+	}
+	if "" != job.ExecuteMode && models.ExecuteModeSync != job.ExecuteMode && models.ExecuteModeAsync != job.ExecuteMode {
+		return errors.Errorf("不支持的执行模式：%s", job.ExecuteMode)
+	}
+	switch job.ExecutorSelectStrategy {
+	case models.ExecutorSelectStrategySharding, models.ExecutorSelectStrategyRandom, models.ExecutorSelectStrategyRound,
+		models.ExecutorSelectStrategyWeightRandom, models.ExecutorSelectStrategyWeightRound, models.ExecutorSelectStrategyBroadcast,
+		models.ExecutorSelectStrategyConsistentHash, models.ExecutorSelectStrategyLeastActive, models.ExecutorSelectStrategyResponseTime:
+	default:
+		return errors.Errorf("不支持的执行节点选择策略：%s", job.ExecutorSelectStrategy)
+	}
+	if models.ExecutorSelectStrategyConsistentHash == job.ExecutorSelectStrategy && "" != job.HashKeyParam {
+		if _, exist := stringutil.KVsToMap(job.HttpParam, "|")[job.HashKeyParam]; !exist {
+			return errors.Errorf("http参数中不存在一致性哈希键参数：%s", job.HashKeyParam)
+		}
+	}
+	switch job.GetShardingStrategy() {
+	case models.ShardingStrategyEven, models.ShardingStrategyWeight, models.ShardingStrategySticky:
+	default:
+		return errors.Errorf("不支持的分片策略：%s", job.ShardingStrategy)
+	}
+	switch job.GetShardSource() {
+	case models.ShardSourceStatic:
+	case models.ShardSourceHttp:
+		if !strings.HasPrefix(job.ShardSourceUrl, "http://") && !strings.HasPrefix(job.ShardSourceUrl, "https://") {
+			return errors.Errorf("分片来源接口地址错误：%s", job.ShardSourceUrl)
+		}
+	case models.ShardSourceDate:
+		if _, err := models.ParseShardDateRange(job.ShardDateRange); err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("不支持的分片来源：%s", job.ShardSource)
+	}
+	switch job.GetBroadcastSuccessRule() {
+	case models.BroadcastSuccessRuleAll, models.BroadcastSuccessRuleQuorum, models.BroadcastSuccessRuleOne:
+	default:
+		return errors.Errorf("不支持的广播成功规则：%s", job.BroadcastSuccessRule)
+	}
+	switch job.GetConcurrencyPolicy() {
+	case models.ConcurrencyPolicyAllow, models.ConcurrencyPolicyForbid, models.ConcurrencyPolicyReplace:
+	default:
+		return errors.Errorf("不支持的并发策略：%s", job.ConcurrencyPolicy)
+	}
+	if job.MaxConcurrency < 0 {
+		return errors.Errorf("同时执行的最大数量不能小于0")
+	}
+	if job.EffectiveFrom < 0 || job.EffectiveUntil < 0 {
+		return errors.Errorf("有效期时间不能小于0")
+	}
+	if job.EffectiveUntil > 0 && job.EffectiveFrom >= job.EffectiveUntil {
+		return errors.Errorf("有效期截止时间必须晚于开始时间")
+	}
+	if models.TriggerTypeOnce == job.GetTriggerType() && ((job.EffectiveFrom > 0 && job.TriggerTime < job.EffectiveFrom) ||
+		(job.EffectiveUntil > 0 && job.TriggerTime > job.EffectiveUntil)) {
+		return errors.Errorf("一次性作业的触发时间不在有效期内")
+	}
+	for _, calendarId := range job.CalendarIds {
+		if _, err := models.GetCalendar(stringutil.ToUintSafe(calendarId)); err != nil {
+			return errors.Errorf("日历不存在：%s", calendarId)
+		}
+	}
+	if _, err := models.ParseLabelSelector(job.ExecutorSelector); err != nil {
+		return errors.Errorf("标签选择器错误：%s", err.Error())
+	}
+	if "" != job.ExecutorGroupId {
+		if _, err := models.GetExecutorGroup(stringutil.ToUintSafe(job.ExecutorGroupId)); err != nil {
+			return errors.Errorf("执行器组不存在：%s", job.ExecutorGroupId)
+		}
+	}
+	switch job.GetCalendarAction() {
+	case models.CalendarActionSkip, models.CalendarActionDefer:
+	default:
+		return errors.Errorf("不支持的日历策略：%s", job.CalendarAction)
+	}
+	switch job.GetMisfirePolicy() {
+	case models.MisfirePolicyFireOnce, models.MisfirePolicyFireAll, models.MisfirePolicySkip:
+	default:
+		return errors.Errorf("不支持的错发策略：%s", job.MisfirePolicy)
+	}
+	if job.MisfireMaxFires < 0 {
+		return errors.Errorf("最大补偿次数不能小于0")
+	}
+	if "" != job.SuccessRegex {
+		if _, err := regexp.Compile(job.SuccessRegex); err != nil {
+			return errors.Errorf("成功判定正则表达式错误：%s", err.Error())
+		}
+	}
+	return nil
+}
+
 func validateCron(c *gin.Context) {
 	spec, _ := url.QueryUnescape(c.Query("spec"))
 	if err := icron.ValidateCronSpec(spec); err != nil {
@@ -92,6 +297,38 @@ func validateCron(c *gin.Context) {
 	respondData(c, true)
 }
 
+func previewCron(c *gin.Context) {
+	spec, _ := url.QueryUnescape(c.Query("spec"))
+	timezone, _ := url.QueryUnescape(c.Query("timezone"))
+	job := &models.Job{
+		Cron:             spec,
+		Timezone:         timezone,
+		Timeout:          stringutil.ToIntSafe(c.Query("timeout")),
+		RetryCount:       stringutil.ToIntSafe(c.Query("retry_count")),
+		RetryWaitTime:    stringutil.ToIntSafe(c.Query("retry_wait_time")),
+		RetryBackoff:     c.Query("retry_backoff"),
+		RetryMaxWaitTime: stringutil.ToIntSafe(c.Query("retry_max_wait_time")),
+		ExecuteDeadline:  stringutil.ToIntSafe(c.Query("execute_deadline")),
+	}
+	job.EffectiveFrom, _ = strconv.ParseInt(c.Query("effective_from"), 10, 64)
+	job.EffectiveUntil, _ = strconv.ParseInt(c.Query("effective_until"), 10, 64)
+	if err := icron.ValidateTimezone(timezone); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err := icron.ValidateCronSpec(spec); nil != err {
+		respond400(c, "Cron表达式错误: "+err.Error())
+		return
+	}
+
+	preview, err := internal.PreviewSchedule(job, stringutil.ToIntSafe(c.Query("count")))
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	respondData(c, preview)
+}
+
 func updateJobStatus(c *gin.Context) {
 	id := stringutil.ToUintSafe(c.Param("id"))
 	status, err := strconv.Atoi(c.Param("status"))
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package routes
+
+import (
+	"strings"
+
+	"gojob/internal"
+	"gojob/models"
+	"gojob/util/stringutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 校验执行节点注册信息
+func checkExecutorInstance(instance *models.ExecutorInstance) error {
+	if "" == instance.AppName {
+		return errors.Errorf("应用名称不能为空")
+	}
+	if strings.Contains(instance.AppName, "|") {
+		return errors.Errorf("应用名称不能包含字符'|'")
+	}
+	if "" == instance.Address {
+		return errors.Errorf("执行节点地址不能为空")
+	}
+	if instance.Weight < 0 {
+		return errors.Errorf("权重不能小于0")
+	}
+	return nil
+}
+
+// 执行节点注册，执行节点启动时调用一次，之后定期调用心跳接口续约
+func registerExecutor(c *gin.Context) {
+	instance := new(models.ExecutorInstance)
+	err := c.BindJSON(instance)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err = checkExecutorInstance(instance); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if 0 == instance.Weight {
+		instance.Weight = 1
+	}
+
+	err = internal.RegisterExecutor(instance)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+// 执行节点心跳续约，返回错误时执行节点应当重新注册
+func heartbeatExecutor(c *gin.Context) {
+	instance := new(models.ExecutorInstance)
+	err := c.BindJSON(instance)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err = checkExecutorInstance(instance); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.HeartbeatExecutor(instance.AppName, instance.Address)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+// 执行节点注销
+func unregisterExecutor(c *gin.Context) {
+	instance := new(models.ExecutorInstance)
+	err := c.BindJSON(instance)
+	if nil != err {
+		respond400(c, err.Error())
+		return
+	}
+	if err = checkExecutorInstance(instance); nil != err {
+		respond400(c, err.Error())
+		return
+	}
+
+	err = internal.UnregisterExecutor(instance.AppName, instance.Address)
+	if nil != err {
+		respond500(c, err.Error())
+		return
+	}
+	respondOK(c)
+}
+
+func searchExecutorInstance(c *gin.Context) {
+	list := internal.SelectExecutorInstanceList(c.Query("app_name"))
+	if "" == c.Query("page_num") || "" == c.Query("page_size") {
+		respondData(c, list)
+		return
+	}
+
+	pageSize := stringutil.ToIntSafe(c.Query("page_size"))
+	startIndex := (stringutil.ToIntSafe(c.Query("page_num")) - 1) * pageSize
+	slice := make([]*models.ExecutorInstance, 0)
+	for i := 0; i < pageSize; i++ {
+		index := startIndex + i
+		if index >= 0 && index < len(list) {
+			slice = append(slice, list[index])
+		}
+	}
+	respondPage(c, &models.Page{
+		Total: int64(len(list)),
+		Data:  slice,
+	})
+}
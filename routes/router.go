@@ -18,6 +18,7 @@
 package routes
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io/ioutil"
@@ -49,6 +50,8 @@ import (
 const (
 	httpLogFileName = "http.log"
 	version         = "v1"
+	// 签名接口请求体的最大字节数
+	signedBodyLimit = 1 << 20
 )
 
 var router *gin.Engine
@@ -302,6 +305,21 @@ func signMiddleware() gin.HandlerFunc {
 		}
 
 		plaintext := c.Request.RequestURI + timestamp
+		if nil != c.Request.Body {
+			body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, signedBodyLimit))
+			if err != nil && len(body) >= signedBodyLimit {
+				c.String(http.StatusRequestEntityTooLarge, "请求体过大")
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.String(http.StatusBadRequest, "读取请求体错误")
+				c.Abort()
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			plaintext = plaintext + string(body)
+		}
 		backstage := internal.Signature(plaintext)
 
 		if backstage != sign {
@@ -18,6 +18,7 @@
 package routes
 
 import (
+	"gojob/internal"
 	"gojob/models"
 	"gojob/util/logs"
 	"gojob/util/stringutil"
@@ -58,6 +59,27 @@ func getTrace(c *gin.Context) {
 	}
 }
 
+func getShardTraces(c *gin.Context) {
+	traceId := stringutil.ToUintSafe(c.Param("id"))
+	ls, err := models.SelectShardTraces(traceId)
+	if nil != err {
+		logs.Error(err.Error())
+		respond500(c, err.Error())
+	} else {
+		respondData(c, ls)
+	}
+}
+
+func rerunFailedShards(c *gin.Context) {
+	traceId := stringutil.ToUintSafe(c.Param("id"))
+	err := internal.RerunFailedShards(traceId)
+	if nil != err {
+		respond500(c, err.Error())
+	} else {
+		respondOK(c)
+	}
+}
+
 func cleanTrace(c *gin.Context) {
 	temp := struct {
 		JobId string
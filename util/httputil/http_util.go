@@ -18,7 +18,10 @@
 package httputil
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -82,16 +85,25 @@ func (this *HttpClient) SetTransport(transport http.RoundTripper) *HttpClient {
 	return this
 }
 
-func (this *HttpClient) retryNecessary(res *http.Response) bool {
+func (this *HttpClient) retryNecessary(res *http.Response, conditions []RetryConditionFunc) bool {
 	for _, condition := range this.RetryConditions {
 		if condition(res) {
 			return true
 		}
 	}
+	for _, condition := range conditions {
+		if condition(res) {
+			return true
+		}
+	}
 	return false
 }
 
 func (this *HttpClient) Execute(request *http.Request) (*http.Response, error) {
+	return this.execute(request, nil)
+}
+
+func (this *HttpClient) execute(request *http.Request, conditions []RetryConditionFunc) (*http.Response, error) {
 	ctx := request.Context()
 	startTime := time.Now().UnixNano()
 	res, err := this.client.Do(request)
@@ -103,11 +115,24 @@ func (this *HttpClient) Execute(request *http.Request) (*http.Response, error) {
 	if this.RetryCount < 1 {
 		return res, err
 	} else {
-		if this.retryNecessary(res) {
+		if this.retryNecessary(res, conditions) {
 			for i := 0; i < this.RetryCount; i++ {
+				if nil != ctx.Err() {
+					return res, err
+				}
 				logs.Infof("%s %s 第%d次重试", request.Method, request.URL.String(), i+1)
+				if nil != res {
+					res.Body.Close()
+				}
+				if nil != request.GetBody {
+					body, bodyErr := request.GetBody()
+					if nil != bodyErr {
+						return nil, bodyErr
+					}
+					request.Body = body
+				}
 				res, err = this.client.Do(request)
-				if !this.retryNecessary(res) || (i+1) == this.RetryCount {
+				if !this.retryNecessary(res, conditions) || (i+1) == this.RetryCount {
 					return res, err
 				}
 				select {
@@ -130,11 +155,16 @@ func (this *HttpClient) NewRequest() *HttpRequest {
 }
 
 type HttpRequest struct {
-	ctx        context.Context
-	httpClient *HttpClient
-	method     string
-	headers    map[string]string
-	parameters map[string]string
+	ctx             context.Context
+	httpClient      *HttpClient
+	method          string
+	headers         map[string]string
+	parameters      map[string]string
+	contentType     string
+	retryConditions []RetryConditionFunc
+	retryPolicy     *RetryPolicy
+	attemptListener AttemptListener
+	attemptGate     AttemptGate
 }
 
 func (this *HttpRequest) SetContext(context context.Context) *HttpRequest {
@@ -152,14 +182,89 @@ func (this *HttpRequest) AddParameter(key string, val string) *HttpRequest {
 	return this
 }
 
+// 添加仅对当前请求生效的重试条件
+func (this *HttpRequest) AddRetryCondition(retryCondition RetryConditionFunc) *HttpRequest {
+	if retryCondition != nil {
+		this.retryConditions = append(this.retryConditions, retryCondition)
+	}
+	return this
+}
+
+// 设置仅对当前请求生效的重试策略，设置后忽略HttpClient的重试次数和重试间隔
+func (this *HttpRequest) SetRetryPolicy(retryPolicy *RetryPolicy) *HttpRequest {
+	this.retryPolicy = retryPolicy
+	return this
+}
+
+// 设置每次请求尝试结束时的回调，仅在设置了重试策略时生效
+func (this *HttpRequest) SetAttemptListener(listener AttemptListener) *HttpRequest {
+	this.attemptListener = listener
+	return this
+}
+
+// 设置每次请求尝试前的回调，仅在设置了重试策略时生效
+func (this *HttpRequest) SetAttemptGate(gate AttemptGate) *HttpRequest {
+	this.attemptGate = gate
+	return this
+}
+
+// 设置请求体的Content-Type
+func (this *HttpRequest) SetContentType(contentType string) *HttpRequest {
+	this.contentType = contentType
+	return this
+}
+
 func (this *HttpRequest) Get(url string) (*http.Response, error) {
+	return this.Do(http.MethodGet, url, nil)
+}
+
+func (this *HttpRequest) Post(url string, body []byte) (*http.Response, error) {
+	return this.Do(http.MethodPost, url, body)
+}
+
+func (this *HttpRequest) Put(url string, body []byte) (*http.Response, error) {
+	return this.Do(http.MethodPut, url, body)
+}
+
+func (this *HttpRequest) Delete(url string, body []byte) (*http.Response, error) {
+	return this.Do(http.MethodDelete, url, body)
+}
+
+// 发送请求，body可以为nil
+func (this *HttpRequest) Do(method string, url string, body []byte) (*http.Response, error) {
 	requestUrl := stringutil.BuildQueryString(url, this.parameters)
-	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	var request *http.Request
+	var err error
+	if len(body) > 0 {
+		request, err = http.NewRequest(method, requestUrl, bytes.NewReader(body))
+	} else {
+		request, err = http.NewRequest(method, requestUrl, nil)
+	}
 	if nil != err {
 		return nil, errors.Errorf("NewRequest 错误 - %s", err.Error())
 	}
+	if nil != this.ctx {
+		request = request.WithContext(this.ctx)
+	}
 	for k, v := range this.headers {
 		request.Header.Add(k, v)
 	}
-	return this.httpClient.Execute(request)
+	if "" != this.contentType && len(body) > 0 {
+		request.Header.Set("Content-Type", this.contentType)
+	}
+	if nil != this.retryPolicy {
+		return this.httpClient.executeWithPolicy(request, this.retryPolicy, this.retryConditions, this.attemptListener, this.attemptGate)
+	}
+	return this.httpClient.execute(request, this.retryConditions)
+}
+
+// 读取响应体，最多读取limit个字节；读取后响应体可被再次读取
+func ReadBody(res *http.Response, limit int64) ([]byte, error) {
+	if nil == res || nil == res.Body {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, limit))
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
 }
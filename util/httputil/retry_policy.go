@@ -0,0 +1,248 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package httputil
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// 网络错误类别 -- 超时
+	ErrorClassTimeout = "timeout"
+	// 网络错误类别 -- 连接被拒绝
+	ErrorClassRefused = "refused"
+	// 网络错误类别 -- 连接被重置
+	ErrorClassReset = "reset"
+	// 网络错误类别 -- 域名解析失败
+	ErrorClassDns = "dns"
+	// 网络错误类别 -- 连接被提前关闭
+	ErrorClassEof = "eof"
+	// 网络错误类别 -- 其他错误
+	ErrorClassOther = "other"
+	// 网络错误类别 -- 请求被取消或者超过期限，不会重试
+	ErrorClassCanceled = "canceled"
+)
+
+// 可配置的网络错误类别
+var ErrorClasses = []string{ErrorClassTimeout, ErrorClassRefused, ErrorClassReset, ErrorClassDns, ErrorClassEof, ErrorClassOther}
+
+// 重试策略
+type RetryPolicy struct {
+	RetryCount      int           // 最大重试次数
+	InitialInterval time.Duration // 首次重试间隔
+	MaxInterval     time.Duration // 最大重试间隔，0为不限制
+	Multiplier      float64       // 重试间隔的增长倍数，不大于1时为固定间隔
+	Jitter          bool          // 是否在[0,重试间隔]内随机等待
+	StatusCodes     []string      // 可重试的状态码，支持5xx形式；为空时除408、429外的4xx不重试，其余按重试条件判断
+	ErrorClasses    []string      // 可重试的网络错误类别；为空时按重试条件判断
+}
+
+// 一次请求尝试
+type Attempt struct {
+	Number   int            // 第几次请求，从1开始
+	Response *http.Response // 响应，请求错误时为nil
+	Err      error          // 请求错误
+	Elapsed  time.Duration  // 耗时
+	Retry    bool           // 是否还会重试
+	Wait     time.Duration  // 下一次重试前的等待时间
+}
+
+// 请求尝试结束时的回调
+type AttemptListener func(attempt *Attempt)
+
+// 第number次请求尝试开始前的回调，返回错误时放弃请求并返回该错误
+type AttemptGate func(number int) error
+
+// 第retry次重试的间隔上限，按增长倍数指数增长，不超过最大重试间隔
+func (this *RetryPolicy) Interval(retry int) time.Duration {
+	interval := float64(this.InitialInterval)
+	if this.Multiplier > 1 {
+		for i := 1; i < retry; i++ {
+			interval = interval * this.Multiplier
+			if this.MaxInterval > 0 && interval >= float64(this.MaxInterval) {
+				break
+			}
+		}
+	}
+	if this.MaxInterval > 0 && interval > float64(this.MaxInterval) {
+		return this.MaxInterval
+	}
+	return time.Duration(interval)
+}
+
+// 第retry次重试前的等待时间，开启抖动时在[0,重试间隔]内均匀随机
+func (this *RetryPolicy) Backoff(retry int) time.Duration {
+	interval := this.Interval(retry)
+	if this.Jitter && interval > 0 {
+		return time.Duration(rand.Int63n(int64(interval) + 1))
+	}
+	return interval
+}
+
+// 状态码是否可重试
+func (this *RetryPolicy) RetryableStatus(statusCode int) bool {
+	code := strconv.Itoa(statusCode)
+	for _, v := range this.StatusCodes {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == code || (len(v) == 3 && strings.HasSuffix(v, "xx") && v[0] == code[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 请求错误是否可重试
+func (this *RetryPolicy) RetryableError(err error) bool {
+	class := ErrorClass(err)
+	if ErrorClassCanceled == class {
+		return false
+	}
+	for _, v := range this.ErrorClasses {
+		if strings.TrimSpace(v) == class {
+			return true
+		}
+	}
+	return false
+}
+
+// 未配置错误类别时，请求错误与未配置状态码时一样按重试条件判断，与不设置重试策略时的行为保持一致；
+// 未配置状态码时，除408和429外的4xx是请求本身的问题，重试也不会成功，不再重试
+func (this *RetryPolicy) retryNecessary(res *http.Response, err error, conditions []RetryConditionFunc) bool {
+	if nil != err {
+		if ErrorClassCanceled == ErrorClass(err) {
+			return false
+		}
+		if len(this.ErrorClasses) > 0 {
+			return this.RetryableError(err)
+		}
+	} else if len(this.StatusCodes) > 0 {
+		return this.RetryableStatus(res.StatusCode)
+	} else if clientError(res.StatusCode) {
+		return false
+	}
+	for _, condition := range conditions {
+		if condition(res) {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否为不可重试的客户端错误
+func clientError(statusCode int) bool {
+	if http.StatusRequestTimeout == statusCode || http.StatusTooManyRequests == statusCode {
+		return false
+	}
+	return statusCode >= 400 && statusCode < 500
+}
+
+// 获取请求错误的类别
+func ErrorClass(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if context.Canceled == err || context.DeadlineExceeded == err {
+		return ErrorClassCanceled
+	}
+	if io.EOF == err || io.ErrUnexpectedEOF == err {
+		return ErrorClassEof
+	}
+	if _, ok := err.(*net.DNSError); ok {
+		return ErrorClassDns
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		if _, ok := opErr.Err.(*net.DNSError); ok {
+			return ErrorClassDns
+		}
+		inner := opErr.Err
+		if syscallErr, ok := inner.(*os.SyscallError); ok {
+			inner = syscallErr.Err
+		}
+		switch inner {
+		case syscall.ECONNREFUSED:
+			return ErrorClassRefused
+		case syscall.ECONNRESET, syscall.EPIPE:
+			return ErrorClassReset
+		}
+	}
+	return ErrorClassOther
+}
+
+// 按重试策略执行请求，每次请求前经过gate，每次请求结束后回调listener
+func (this *HttpClient) executeWithPolicy(request *http.Request, policy *RetryPolicy, conditions []RetryConditionFunc, listener AttemptListener, gate AttemptGate) (*http.Response, error) {
+	ctx := request.Context()
+	conditions = append(append(make([]RetryConditionFunc, 0, len(this.RetryConditions)+len(conditions)), this.RetryConditions...), conditions...)
+	for number := 1; ; number++ {
+		if number > 1 && nil != request.GetBody {
+			body, bodyErr := request.GetBody()
+			if nil != bodyErr {
+				return nil, bodyErr
+			}
+			request.Body = body
+		}
+		if nil != gate {
+			if gateErr := gate(number); nil != gateErr {
+				return nil, gateErr
+			}
+		}
+		begin := time.Now()
+		res, err := this.client.Do(request)
+		attempt := &Attempt{
+			Number:   number,
+			Response: res,
+			Err:      err,
+			Elapsed:  time.Since(begin),
+		}
+		if number <= policy.RetryCount && nil == ctx.Err() && policy.retryNecessary(res, err, conditions) {
+			attempt.Retry = true
+			attempt.Wait = policy.Backoff(number)
+			// 等待后已超过期限的不再重试
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(attempt.Wait).After(deadline) {
+				attempt.Retry = false
+				attempt.Wait = 0
+			}
+		}
+		if nil != listener {
+			listener(attempt)
+		}
+		if !attempt.Retry {
+			return res, err
+		}
+		if nil != res {
+			res.Body.Close()
+		}
+		select {
+		case <-time.After(attempt.Wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialInterval: time.Second,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, interval := range expected {
+		if actual := policy.Backoff(i + 1); actual != interval {
+			t.Errorf("retry %d: expected %v, actual %v", i+1, interval, actual)
+		}
+	}
+
+	policy.Jitter = true
+	for i := 0; i < 100; i++ {
+		if wait := policy.Backoff(3); wait < 0 || wait > 4*time.Second {
+			t.Fatalf("jitter out of range: %v", wait)
+		}
+	}
+}
+
+func TestRetryPolicyStatus(t *testing.T) {
+	status := []int{503, 503, 400}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	policy := &RetryPolicy{
+		RetryCount:      5,
+		InitialInterval: time.Millisecond,
+		StatusCodes:     []string{"429", "5xx"},
+	}
+	attempts := make([]*Attempt, 0)
+	res, err := NewHttpClient().NewRequest().
+		SetRetryPolicy(policy).
+		SetAttemptListener(func(attempt *Attempt) {
+			attempts = append(attempts, attempt)
+		}).
+		Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// 400不在可重试的状态码中，第3次请求后停止重试
+	if 400 != res.StatusCode || 3 != requests || 3 != len(attempts) {
+		t.Fatalf("expected 3 requests ending with 400, actual %d requests, status %d", requests, res.StatusCode)
+	}
+	if !attempts[0].Retry || !attempts[1].Retry || attempts[2].Retry {
+		t.Errorf("unexpected retry flags")
+	}
+}
+
+func TestRetryPolicyDefaultStatus(t *testing.T) {
+	for status, expected := range map[int]int{400: 1, 404: 1, 408: 3, 429: 3, 503: 3} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			requests++
+		}))
+		res, err := NewHttpClient().NewRequest().
+			SetRetryPolicy(&RetryPolicy{RetryCount: 2}).
+			AddRetryCondition(func(res *http.Response) bool {
+				return res.StatusCode != 200
+			}).
+			Get(server.URL)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if requests != expected {
+			t.Errorf("status %d: expected %d requests, actual %d", status, expected, requests)
+		}
+	}
+}
+
+func TestRetryPolicyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	address := server.URL
+	server.Close()
+
+	for _, classes := range [][]string{nil, {ErrorClassRefused}} {
+		attempts := 0
+		_, err := NewHttpClient().NewRequest().
+			SetRetryPolicy(&RetryPolicy{RetryCount: 2, ErrorClasses: classes}).
+			SetAttemptListener(func(attempt *Attempt) {
+				attempts++
+				if class := ErrorClass(attempt.Err); ErrorClassRefused != class {
+					t.Errorf("expected refused, actual %s", class)
+				}
+			}).
+			Get(address)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if expected := 1 + 2*len(classes); attempts != expected {
+			t.Errorf("classes %v: expected %d attempts, actual %d", classes, expected, attempts)
+		}
+	}
+}
+
+func TestRetryPolicyDefaultError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	address := server.URL
+	server.Close()
+
+	attempts := 0
+	_, err := NewHttpClient().
+		AddRetryCondition(func(res *http.Response) bool {
+			return res == nil
+		}).
+		NewRequest().
+		SetRetryPolicy(&RetryPolicy{RetryCount: 2}).
+		SetAttemptListener(func(attempt *Attempt) {
+			attempts++
+		}).
+		Get(address)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, actual %d", attempts)
+	}
+}
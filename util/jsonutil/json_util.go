@@ -0,0 +1,107 @@
+/*
+ * Copyright 2020-2021 the original author(https://github.com/wj596)
+ *
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ * </p>
+ */
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 按路径获取JSON中的值，并转换为字符串
+// 路径格式如：code、data.status、$.data.items[0].id
+func GetPathValue(data []byte, path string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return "", err
+	}
+
+	current := root
+	for _, segment := range splitPath(path) {
+		switch v := segment.(type) {
+		case string:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return "", errors.Errorf("路径%s不存在", path)
+			}
+			current, ok = object[v]
+			if !ok {
+				return "", errors.Errorf("路径%s不存在", path)
+			}
+		case int:
+			array, ok := current.([]interface{})
+			if !ok || v < 0 || v >= len(array) {
+				return "", errors.Errorf("路径%s不存在", path)
+			}
+			current = array[v]
+		}
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	}
+}
+
+// 拆分路径，对象属性为string，数组下标为int
+func splitPath(path string) []interface{} {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	segments := make([]interface{}, 0)
+	for _, item := range strings.Split(path, ".") {
+		for "" != item {
+			start := strings.Index(item, "[")
+			if start < 0 {
+				segments = append(segments, item)
+				break
+			}
+			if start > 0 {
+				segments = append(segments, item[:start])
+			}
+			end := strings.Index(item, "]")
+			if end < start {
+				segments = append(segments, item[start:])
+				break
+			}
+			index, err := strconv.Atoi(item[start+1 : end])
+			if err != nil {
+				segments = append(segments, item[start+1:end])
+			} else {
+				segments = append(segments, index)
+			}
+			item = item[end+1:]
+		}
+	}
+	return segments
+}
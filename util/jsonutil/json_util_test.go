@@ -0,0 +1,26 @@
+package jsonutil
+
+import "testing"
+
+func TestGetPathValue(t *testing.T) {
+	data := []byte(`{"code":0,"ok":true,"data":{"status":"done","items":[{"id":11},{"id":12}]}}`)
+	cases := map[string]string{
+		"code":               "0",
+		"$.ok":               "true",
+		"data.status":        "done",
+		"$.data.items[1].id": "12",
+	}
+	for path, expected := range cases {
+		actual, err := GetPathValue(data, path)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err.Error())
+		}
+		if actual != expected {
+			t.Errorf("%s: expected %s, actual %s", path, expected, actual)
+		}
+	}
+
+	if _, err := GetPathValue(data, "data.items[5].id"); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
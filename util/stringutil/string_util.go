@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/satori/go.uuid"
 )
@@ -101,6 +102,28 @@ func BuildQueryString(base string, parameters map[string]string) string {
 	return buffer.String()
 }
 
+// 按字节数截断字符串，不会截断多字节字符
+func Truncate(str string, maxBytes int) string {
+	if len(str) <= maxBytes {
+		return str
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(str[end]) {
+		end--
+	}
+	return str[:end]
+}
+
+// 判断切片中是否包含指定字符串
+func InArray(str string, arr []string) bool {
+	for _, v := range arr {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
+
 func IsEmailFormat(email string) bool {
 	pattern := `\w+([-+.]\w+)*@\w+([-.]\w+)*\.\w+([-.]\w+)*`
 	reg := regexp.MustCompile(pattern)
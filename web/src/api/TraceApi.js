@@ -14,6 +14,25 @@ traceApi.getTrace = function (traceId) {
     ,method: 'get'
   })
 }
+traceApi.rerunFailedShards = function (traceId) {
+  return request({
+    url: '/traces/' + traceId + '/rerun_failed_shards'
+    ,method: 'post'
+  })
+}
+traceApi.getExecutions = function (_params) {
+  return request({
+    url: '/executions'
+    ,method: 'get'
+    ,params: _params
+  })
+}
+traceApi.cancelExecution = function (traceId) {
+  return request({
+    url: '/executions/' + traceId + '/cancel'
+    ,method: 'post'
+  })
+}
 traceApi.cleanTrace = function (_data) {
   return request({
     url: '/traces/clean'